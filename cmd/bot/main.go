@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const matchTimeout = time.Minute
+
+type botConfig struct {
+	name     string
+	strategy string
+	size     int
+	interval time.Duration
+	seed     int64
+}
+
+func main() {
+	n := flag.Int("n", 1, "how many bots to run")
+	strategy := flag.String("strategy", "random", fmt.Sprintf("how the bots play, one of %v", bot.Strategies))
+	size := flag.Int("size", 2, "the size of the games the bots automatch into")
+	interval := flag.Duration("interval", 3*time.Second, "how long each bot waits between commands")
+	prefix := flag.String("name", "bot", "username prefix, bots are numbered from 1")
+	flag.Parse()
+	if *n < 1 || *interval <= 0 {
+		log.Fatalf("need at least one bot and a positive interval")
+	}
+	if _, err := bot.NewStrategy(*strategy, nil); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 1; i <= *n; i++ {
+		cfg := botConfig{
+			name:     fmt.Sprintf("%s%d", *prefix, i),
+			strategy: *strategy,
+			size:     *size,
+			interval: *interval,
+			seed:     time.Now().UnixNano() + int64(i),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runBot(cfg); err != nil {
+				log.Printf("%s stopped: %v", cfg.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runBot plays one game through the same client wiring as a human player.
+func runBot(cfg botConfig) error {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	strategy, err := bot.NewStrategy(cfg.strategy, rng)
+	if err != nil {
+		return err
+	}
+
+	connName := fmt.Sprintf("peril_bot.%d.%s", os.Getpid(), cfg.name)
+	conn, err := pubsub.DialNamed(client.PlayerConnString, connName)
+	if err != nil {
+		return fmt.Errorf("could not connect to RabbitMQ: %v", err)
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	sess, err := register(conn, connName, cfg.name, rng)
+	if err != nil {
+		return err
+	}
+	if conn, err = client.Connect(conn, connName, sess); err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("could not open channel: %v", err)
+	}
+	defer ch.Close()
+
+	gs := gamelogic.NewGameState(sess.Username)
+	c := client.New(conn, ch, gs, sess)
+	kicked := make(chan routing.AdminNotice, 1)
+	c.OnKick = func(n routing.AdminNotice) {
+		kicked <- n
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("subscribe failed: %v", err)
+	}
+	defer c.Leave()
+
+	l, err := c.EnterLobby()
+	if err != nil {
+		return err
+	}
+	if err := l.AutoMatch(cfg.size); err != nil {
+		return err
+	}
+	if _, err := l.AwaitGame(matchTimeout); err != nil {
+		return err
+	}
+	start, err := l.Ready()
+	if err != nil {
+		return err
+	}
+	if err := c.JoinGame(start); err != nil {
+		return fmt.Errorf("subscribe failed: %v", err)
+	}
+	log.Printf("%s playing %s in game %s", sess.Username, cfg.strategy, start.GameID)
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case n := <-kicked:
+			return fmt.Errorf("removed by the server: %s", n.Action)
+		case <-ticker.C:
+		}
+		if gs.IsOver() {
+			log.Printf("%s finished game %s", sess.Username, start.GameID)
+			return nil
+		}
+		if words := strategy.Next(bot.NewView(gs)); words != nil {
+			c.Execute(words)
+		}
+	}
+}
+
+// register falls back to a random suffix while the bot's name is taken.
+func register(conn *amqp.Connection, connName, name string, rng *rand.Rand) (client.Session, error) {
+	username := name
+	for attempt := 0; attempt < 5; attempt++ {
+		sess, err := client.Register(conn, connName, username)
+		var rejected *client.RejectedError
+		if !errors.As(err, &rejected) {
+			return sess, err
+		}
+		username = fmt.Sprintf("%s_%d", name, rng.Intn(1000))
+	}
+	return client.Session{}, fmt.Errorf("could not find a free username for %s", name)
+}
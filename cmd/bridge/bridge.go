@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mqtt"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// allGames stands in for the game ID of server-wide pauses.
+const allGames = "all"
+
+type warEvent struct {
+	GameID   string
+	Attacker string
+	Defender string
+	Location gamelogic.Location
+	At       time.Time
+}
+
+type pauseEvent struct {
+	GameID   string
+	Paused   bool
+	Reason   string
+	ResumeAt time.Time
+}
+
+// bridge republishes game events to MQTT. Territory, pause state and results
+// are retained so a device that connects later sees the current state at
+// once; wars are only news.
+type bridge struct {
+	conn   *amqp.Connection
+	mqtt   *mqtt.Client
+	topics mapping
+	qos    byte
+	world  *world.World
+
+	mu        *sync.Mutex
+	territory map[string]map[gamelogic.Location]string
+}
+
+func newBridge(conn *amqp.Connection, client *mqtt.Client, topics mapping, qos byte) *bridge {
+	return &bridge{
+		conn:      conn,
+		mqtt:      client,
+		topics:    topics,
+		qos:       qos,
+		world:     world.New(),
+		mu:        &sync.Mutex{},
+		territory: map[string]map[gamelogic.Location]string{},
+	}
+}
+
+func (b *bridge) handlerArmyMoves() func(gamelogic.ArmyMove) pubsub.Acktype {
+	return func(mv gamelogic.ArmyMove) pubsub.Acktype {
+		b.world.Update(mv.GameID, mv.Player)
+		return b.publishTerritory(mv.GameID)
+	}
+}
+
+func (b *bridge) handlerArmyReports() func(gamelogic.ArmyReport) pubsub.Acktype {
+	return func(r gamelogic.ArmyReport) pubsub.Acktype {
+		b.world.Update(r.GameID, r.Player)
+		return b.publishTerritory(r.GameID)
+	}
+}
+
+func (b *bridge) handlerWar() func(gamelogic.RecognitionOfWar) pubsub.Acktype {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.Acktype {
+		// wars don't carry their game, but the attacker's army does
+		a, ok := b.world.Army(rw.Attacker.Username)
+		if !ok {
+			return pubsub.Ack
+		}
+		return b.publish(b.topics.War, a.GameID, "", false, warEvent{
+			GameID:   a.GameID,
+			Attacker: rw.Attacker.Username,
+			Defender: rw.Defender.Username,
+			Location: rw.Location(),
+			At:       time.Now(),
+		})
+	}
+}
+
+func (b *bridge) handlerGameOver() func(gamelogic.GameOver) pubsub.Acktype {
+	return func(over gamelogic.GameOver) pubsub.Acktype {
+		return b.publish(b.topics.GameOver, over.GameID, "", true, over)
+	}
+}
+
+func (b *bridge) handlerPause() func(routing.PlayingState) pubsub.Acktype {
+	return func(ps routing.PlayingState) pubsub.Acktype {
+		if ps.Target != "" {
+			// a single player's pause says nothing about the game
+			return pubsub.Ack
+		}
+		gameID := ps.GameID
+		if gameID == "" {
+			gameID = allGames
+		}
+		return b.publish(b.topics.Pause, gameID, "", true, pauseEvent{
+			GameID:   ps.GameID,
+			Paused:   ps.IsPaused,
+			Reason:   ps.Reason,
+			ResumeAt: ps.ResumeAt,
+		})
+	}
+}
+
+// publishTerritory sends the game's territory when it changes, and the owner
+// of each location that changed hands. The territory is only remembered once
+// everything is published, so a requeued move publishes whatever was missed.
+func (b *bridge) publishTerritory(gameID string) pubsub.Acktype {
+	players := []gamelogic.Player{}
+	for _, a := range b.world.Armies(gameID) {
+		players = append(players, a.Player)
+	}
+	owners := gamelogic.Territory(players)
+
+	// held while publishing so moves and reports of the same game can't
+	// each publish against territory the other is replacing
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old, seen := b.territory[gameID]
+	if !seen {
+		b.watchPauses(gameID)
+	}
+
+	changed := false
+	for _, loc := range gamelogic.Locations() {
+		if seen && old[loc] == owners[loc] {
+			continue
+		}
+		changed = true
+		if ack := b.publishRaw(b.topics.Location, gameID, string(loc), true, []byte(owners[loc])); ack != pubsub.Ack {
+			return ack
+		}
+	}
+	if changed {
+		if ack := b.publish(b.topics.Territory, gameID, "", true, owners); ack != pubsub.Ack {
+			return ack
+		}
+	}
+	b.territory[gameID] = owners
+	return pubsub.Ack
+}
+
+// watchPauses binds the pause queue to a game's pauses the first time the
+// game is seen, since direct keys can't be matched by a wildcard. Binding
+// again after a failed publish is harmless.
+func (b *bridge) watchPauses(gameID string) {
+	err := pubsub.AddBinding(b.conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseGameKey(gameID))
+	if err != nil {
+		log.Printf("could not follow pauses of game %s: %v", gameID, err)
+	}
+}
+
+func (b *bridge) publish(template, gameID, location string, retain bool, v any) pubsub.Acktype {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("could not encode event: %v", err)
+		return pubsub.NackDiscard
+	}
+	return b.publishRaw(template, gameID, location, retain, payload)
+}
+
+func (b *bridge) publishRaw(template, gameID, location string, retain bool, payload []byte) pubsub.Acktype {
+	if template == "" {
+		return pubsub.Ack
+	}
+	t := topic(template, gameID, location)
+	if err := b.mqtt.Publish(t, payload, b.qos, retain); err != nil {
+		log.Printf("could not publish to %s: %v", t, err)
+		return pubsub.NackRequeue
+	}
+	return pubsub.Ack
+}
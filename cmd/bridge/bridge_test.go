@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mqtt"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mqtt/mqtttest"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// expectPublish reads a QoS 1 PUBLISH, checks it is retained and acks it.
+func expectPublish(t *testing.T, b *mqtttest.Broker, topic string) []byte {
+	t.Helper()
+	pub := b.ExpectPublish()
+	if pub.Topic != topic {
+		t.Fatalf("PUBLISH to %s, want %s", pub.Topic, topic)
+	}
+	if pub.QoS != 1 || !pub.Retain {
+		t.Errorf("PUBLISH to %s at QoS %d retained %v, want QoS 1 retained", topic, pub.QoS, pub.Retain)
+	}
+	b.PubAck(pub.ID)
+	return pub.Payload
+}
+
+func dialFakeBroker(t *testing.T) (*mqtt.Client, *mqtttest.Broker) {
+	t.Helper()
+	nc, b := mqtttest.Pipe(t)
+	type result struct {
+		c   *mqtt.Client
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := mqtt.NewClient(nc, mqtt.Options{ClientID: "bridge"})
+		done <- result{c, err}
+	}()
+	b.Accept()
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("NewClient: %v", res.err)
+	}
+	t.Cleanup(func() {
+		b.Close()
+		res.c.Close()
+	})
+	return res.c, b
+}
+
+func TestTopic(t *testing.T) {
+	m := defaultMapping()
+	tests := []struct {
+		template string
+		location string
+		want     string
+	}{
+		{m.War, "", "peril/g1/war"},
+		{m.Territory, "", "peril/g1/territory"},
+		{m.Location, "europe", "peril/g1/territory/europe"},
+		{m.Pause, "", "peril/g1/pause"},
+		{m.GameOver, "", "peril/g1/over"},
+	}
+	for _, tt := range tests {
+		if got := topic(tt.template, "g1", tt.location); got != tt.want {
+			t.Errorf("topic(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMapping(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "topics.json")
+	err := os.WriteFile(path, []byte(`{"war": "", "location": "home/peril/{game}/{location}"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadMapping(path)
+	if err != nil {
+		t.Fatalf("loadMapping: %v", err)
+	}
+	want := defaultMapping()
+	want.War = ""
+	want.Location = "home/peril/{game}/{location}"
+	if m != want {
+		t.Errorf("mapping = %+v, want %+v", m, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"territory": "peril/+/territory"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMapping(path); err == nil {
+		t.Error("a topic with a wildcard was accepted")
+	}
+	if _, err := loadMapping(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("a missing mapping file was accepted")
+	}
+}
+
+// A territory change that fails to publish must be published again when the
+// move is redelivered, so the bridge can't remember it until it's out.
+func TestPublishTerritoryRemembersOnlyPublished(t *testing.T) {
+	client, broker := dialFakeBroker(t)
+	b := newBridge(nil, client, defaultMapping(), 1)
+	// the game is already known, so its pauses are already followed
+	b.territory["g1"] = map[gamelogic.Location]string{}
+	b.world.Update("g1", gamelogic.Player{
+		Username: "alice",
+		Units:    map[int]gamelogic.Unit{1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}},
+	})
+
+	done := make(chan pubsub.Acktype, 1)
+	go func() {
+		done <- b.publishTerritory("g1")
+	}()
+	broker.Expect(mqtttest.PacketPublish)
+	broker.Close()
+	if ack := <-done; ack != pubsub.NackRequeue {
+		t.Fatalf("publishTerritory = %v after losing the broker, want NackRequeue", ack)
+	}
+	if owners := b.territory["g1"]; len(owners) != 0 {
+		t.Fatalf("territory %v was remembered before it was published", owners)
+	}
+
+	b.mqtt, broker = dialFakeBroker(t)
+	go func() {
+		done <- b.publishTerritory("g1")
+	}()
+	if owner := expectPublish(t, broker, "peril/g1/territory/europe"); string(owner) != "alice" {
+		t.Errorf("europe's owner = %q, want alice", owner)
+	}
+	var owners map[gamelogic.Location]string
+	if err := json.Unmarshal(expectPublish(t, broker, "peril/g1/territory"), &owners); err != nil {
+		t.Fatalf("territory payload: %v", err)
+	}
+	if len(owners) != 1 || owners["europe"] != "alice" {
+		t.Errorf("territory = %v", owners)
+	}
+	if ack := <-done; ack != pubsub.Ack {
+		t.Fatalf("publishTerritory = %v, want Ack", ack)
+	}
+	if b.territory["g1"]["europe"] != "alice" {
+		t.Errorf("published territory was not remembered: %v", b.territory["g1"])
+	}
+
+	// nothing changed, so nothing is published and the pipe isn't touched
+	if ack := b.publishTerritory("g1"); ack != pubsub.Ack {
+		t.Fatalf("publishTerritory = %v with nothing to publish, want Ack", ack)
+	}
+}
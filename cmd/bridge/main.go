@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mqtt"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// the bridge's queues get copies of the game traffic without taking any
+// messages from the players' own queues
+const (
+	movesQueue    = "bridge." + routing.ArmyMovesPrefix
+	reportsQueue  = "bridge." + routing.ArmyReportsPrefix
+	warQueue      = "bridge." + routing.WarRecognitionsPrefix
+	gameOverQueue = "bridge." + routing.GameOverPrefix
+	pauseQueue    = "bridge." + routing.PauseKey
+)
+
+func main() {
+	broker := flag.String("mqtt", "localhost:1883", "address of the MQTT broker")
+	username := flag.String("mqtt-user", "", "MQTT username")
+	password := flag.String("mqtt-password", "", "MQTT password")
+	qos := flag.Int("qos", 1, "MQTT QoS to publish with, 0 or 1")
+	mappingPath := flag.String("topics", "", "JSON file mapping events to MQTT topics (defaults to peril/{game}/...)")
+	spectatorPassword := flag.String("spectator-password", "", "broker password for spectators, as set on the server")
+	flag.Parse()
+	if *spectatorPassword == "" {
+		log.Fatalf("-spectator-password is required, the bridge reads every army like a spectator")
+	}
+	if *qos != 0 && *qos != 1 {
+		log.Fatalf("qos must be 0 or 1")
+	}
+	topics, err := loadMapping(*mappingPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	client, err := mqtt.Dial(*broker, mqtt.Options{
+		ClientID:  fmt.Sprintf("peril_bridge.%d", os.Getpid()),
+		Username:  *username,
+		Password:  *password,
+		KeepAlive: 30 * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("could not connect to MQTT broker: %v", err)
+	}
+	defer client.Close()
+	fmt.Println("Peril MQTT bridge connected to", *broker)
+
+	rabbitConnString := fmt.Sprintf("amqp://%s:%s@localhost:5672/", routing.SpectatorUser, url.PathEscape(*spectatorPassword))
+	conn, err := amqp.Dial(rabbitConnString)
+	if err != nil {
+		log.Fatalf("could not connect to RabbitMQ: %v", err)
+	}
+	defer conn.Close()
+	fmt.Println("Peril MQTT bridge connected to RabbitMQ!")
+
+	b := newBridge(conn, client, topics, byte(*qos))
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilServer, movesQueue, routing.AllArmyMovesBinding(), pubsub.QueueTransient, b.handlerArmyMoves())
+	if err != nil {
+		log.Fatalf("could not subscribe to moves: %v", err)
+	}
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilServer, reportsQueue, routing.ArmyReportBinding(), pubsub.QueueTransient, b.handlerArmyReports())
+	if err != nil {
+		log.Fatalf("could not subscribe to army reports: %v", err)
+	}
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, warQueue, routing.WarRecognitionsPrefix+".#", pubsub.QueueTransient, b.handlerWar())
+	if err != nil {
+		log.Fatalf("could not subscribe to wars: %v", err)
+	}
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, gameOverQueue, routing.GameOverPrefix+".*", pubsub.QueueTransient, b.handlerGameOver())
+	if err != nil {
+		log.Fatalf("could not subscribe to game results: %v", err)
+	}
+	// game pauses are bound as games are discovered
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseKey, pubsub.QueueTransient, b.handlerPause())
+	if err != nil {
+		log.Fatalf("could not subscribe to pauses: %v", err)
+	}
+
+	<-client.Done()
+	log.Fatalf("lost the MQTT broker: %v", client.Err())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// mapping names the MQTT topic for each kind of event. {game} and {location}
+// are filled in per message, and an empty topic turns that event off.
+type mapping struct {
+	War       string `json:"war"`
+	Territory string `json:"territory"`
+	Location  string `json:"location"`
+	Pause     string `json:"pause"`
+	GameOver  string `json:"game_over"`
+}
+
+func defaultMapping() mapping {
+	return mapping{
+		War:       "peril/{game}/war",
+		Territory: "peril/{game}/territory",
+		Location:  "peril/{game}/territory/{location}",
+		Pause:     "peril/{game}/pause",
+		GameOver:  "peril/{game}/over",
+	}
+}
+
+// loadMapping reads a JSON mapping over the defaults, so a file only needs
+// the topics it changes.
+func loadMapping(path string) (mapping, error) {
+	m := defaultMapping()
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mapping{}, fmt.Errorf("could not read topic mapping: %v", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return mapping{}, fmt.Errorf("could not parse topic mapping: %v", err)
+	}
+	for _, topic := range []string{m.War, m.Territory, m.Location, m.Pause, m.GameOver} {
+		if strings.ContainsAny(topic, "+#") {
+			return mapping{}, fmt.Errorf("topic %q must not contain wildcards", topic)
+		}
+	}
+	return m, nil
+}
+
+func topic(template, gameID, location string) string {
+	return strings.NewReplacer("{game}", gameID, "{location}", location).Replace(template)
+}
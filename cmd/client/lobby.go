@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var errLeftLobby = errors.New("left the lobby")
+
+// runLobby announces the player to the server lobby and reads lobby commands
+// until the server starts a game the player has readied up for.
+func runLobby(c *client.Client) (routing.PlayingState, error) {
+	l, err := c.EnterLobby()
+	if err != nil {
+		return routing.PlayingState{}, err
+	}
+
+	var start routing.PlayingState
+	started := false
+	commands := newLobbyCommands(l, func(ps routing.PlayingState) {
+		start = ps
+		started = true
+	})
+	gamelogic.SetCompleter(commands.Completer(gamelogic.CompletionValues))
+	commands.PrintHelp()
+	for !started {
+		words, err := c.GetInput()
+		if err != nil {
+			return routing.PlayingState{}, err
+		}
+		if words == nil && gamelogic.InputClosed() {
+			words = []string{"quit"}
+		}
+		err = commands.Run(words)
+		if errors.Is(err, errLeftLobby) {
+			return routing.PlayingState{}, errLeftLobby
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return start, nil
+}
+
+func newLobbyCommands(l *client.Lobby, onStart func(routing.PlayingState)) *gamelogic.Registry {
+	r := gamelogic.NewRegistry("Lobby commands:")
+	r.Register(gamelogic.Command{
+		Name: "games",
+		Run: func(gamelogic.Args) error {
+			return l.List()
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "create",
+		Args:    []gamelogic.Arg{{Name: "size", Type: gamelogic.ArgInt}},
+		Example: "create 2",
+		Run: func(args gamelogic.Args) error {
+			return l.Create(args.Int("size"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "join",
+		Args: []gamelogic.Arg{{Name: "gameID", Type: gamelogic.ArgGameID}},
+		Run: func(args gamelogic.Args) error {
+			return l.Join(args.String("gameID"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "automatch",
+		Args: []gamelogic.Arg{{Name: "size", Type: gamelogic.ArgInt}},
+		Run: func(args gamelogic.Args) error {
+			return l.AutoMatch(args.Int("size"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "ready",
+		Run: func(gamelogic.Args) error {
+			ps, err := l.Ready()
+			if err != nil {
+				return err
+			}
+			onStart(ps)
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "leave",
+		Run: func(gamelogic.Args) error {
+			return l.Leave()
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "quit",
+		Aliases: []string{"exit"},
+		Run: func(gamelogic.Args) error {
+			_ = l.Leave()
+			return errLeftLobby
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Run: func(gamelogic.Args) error {
+			r.PrintHelp()
+			return nil
+		},
+	})
+	return r
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+const defaultWaitTimeout = 30 * time.Second
+
+type scriptLine struct {
+	number int
+	words  []string
+}
+
+// script is a headless client session. The first line must be
+// "login <username>"; the rest are lobby and game commands plus:
+//
+//	wait <duration>
+//	wait <event> [timeout]   event is one of move, war, income, turn, pause,
+//	                         gameover, diplomacy, chat, presence, announcement;
+//	                         each wait uses up one event of that kind
+//	expect units [location] <n>
+//	expect resources <n>
+//	expect paused|over true|false
+//	expect winner <username>
+type script struct {
+	path     string
+	username string
+	lines    []scriptLine
+	// seen counts the handled messages of each kind and waited how many of
+	// them waits have used, so a wait finds an event however early it came
+	seen    map[string]int
+	waited  map[string]int
+	arrived chan struct{}
+	mu      *sync.Mutex
+}
+
+// waitable are the events a script can wait for.
+var waitable = map[string]bool{
+	client.EventMove:         true,
+	client.EventWar:          true,
+	client.EventIncome:       true,
+	client.EventTurn:         true,
+	client.EventPause:        true,
+	client.EventGameOver:     true,
+	client.EventDiplomacy:    true,
+	client.EventChat:         true,
+	client.EventPresence:     true,
+	client.EventAnnouncement: true,
+}
+
+func newScript(path string) *script {
+	return &script{
+		path:    path,
+		seen:    map[string]int{},
+		waited:  map[string]int{},
+		arrived: make(chan struct{}),
+		mu:      &sync.Mutex{},
+	}
+}
+
+func loadScript(path string) (*script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open script: %v", err)
+	}
+	defer f.Close()
+
+	s := newScript(path)
+	scanner := bufio.NewScanner(f)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.lines = append(s.lines, scriptLine{number: number, words: strings.Fields(line)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read script: %v", err)
+	}
+	if len(s.lines) == 0 || s.lines[0].words[0] != "login" || len(s.lines[0].words) != 2 {
+		return nil, fmt.Errorf("%s must start with: login <username>", path)
+	}
+	s.username = s.lines[0].words[1]
+	s.lines = s.lines[1:]
+	return s, nil
+}
+
+// observe counts every handled message so a later wait sees it even if it
+// arrived before the wait started.
+func (s *script) observe(kind string, _ any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[kind]++
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+}
+
+func (s *script) run(c *client.Client) error {
+	c.Observer = s.observe
+	l, err := c.EnterLobby()
+	if err != nil {
+		return err
+	}
+	inGame := false
+	for _, line := range s.lines {
+		select {
+		case <-c.Kicked():
+			return client.ErrKicked
+		default:
+		}
+		fmt.Printf("> %s\n", strings.Join(line.words, " "))
+		err := s.step(c, l, line.words, &inGame)
+		if errors.Is(err, errScriptQuit) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", s.path, line.number, err)
+		}
+	}
+	return nil
+}
+
+var errScriptQuit = errors.New("quit")
+
+func (s *script) step(c *client.Client, l *client.Lobby, words []string, inGame *bool) error {
+	switch words[0] {
+	case "wait":
+		return s.wait(words)
+	case "expect":
+		return expect(c.GameState(), words)
+	}
+
+	if *inGame {
+		if words[0] == "quit" || words[0] == "exit" {
+			if err := c.Run(words); err != nil {
+				return err
+			}
+			return errScriptQuit
+		}
+		return c.Run(words)
+	}
+	switch words[0] {
+	case "games":
+		return l.List()
+	case "create", "automatch":
+		if len(words) < 2 {
+			return fmt.Errorf("usage: %s <size>", words[0])
+		}
+		size, err := strconv.Atoi(words[1])
+		if err != nil {
+			return fmt.Errorf("%s is not a valid game size", words[1])
+		}
+		if words[0] == "create" {
+			err = l.Create(size)
+		} else {
+			err = l.AutoMatch(size)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = l.AwaitGame(defaultWaitTimeout)
+		return err
+	case "join":
+		if len(words) < 2 {
+			return errors.New("usage: join <gameID>")
+		}
+		if err := l.Join(words[1]); err != nil {
+			return err
+		}
+		_, err := l.AwaitGame(defaultWaitTimeout)
+		return err
+	case "leave":
+		return l.Leave()
+	case "ready":
+		start, err := l.Ready()
+		if err != nil {
+			return err
+		}
+		*inGame = true
+		return c.JoinGame(start)
+	case "quit":
+		_ = l.Leave()
+		return errScriptQuit
+	default:
+		return fmt.Errorf("%s is not a lobby command, ready up first", words[0])
+	}
+}
+
+func (s *script) wait(words []string) error {
+	if len(words) < 2 {
+		return errors.New("usage: wait <duration> | wait <event> [timeout]")
+	}
+	if d, err := time.ParseDuration(words[1]); err == nil {
+		time.Sleep(d)
+		return nil
+	}
+	kind := words[1]
+	if !waitable[kind] {
+		return fmt.Errorf("%s is not an event to wait for", kind)
+	}
+	timeout := defaultWaitTimeout
+	if len(words) > 2 {
+		d, err := time.ParseDuration(words[2])
+		if err != nil {
+			return fmt.Errorf("%s is not a valid timeout", words[2])
+		}
+		timeout = d
+	}
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if s.seen[kind] > s.waited[kind] {
+			s.waited[kind]++
+			s.mu.Unlock()
+			return nil
+		}
+		arrived := s.arrived
+		s.mu.Unlock()
+		select {
+		case <-arrived:
+		case <-deadline:
+			return fmt.Errorf("no %s within %s", kind, timeout)
+		}
+	}
+}
+
+func expect(gs *gamelogic.GameState, words []string) error {
+	if len(words) < 3 {
+		return errors.New("usage: expect <what> <value>")
+	}
+	want := words[len(words)-1]
+	var got string
+	switch words[1] {
+	case "units":
+		if _, err := strconv.Atoi(want); err != nil || len(words) > 4 {
+			return errors.New("usage: expect units [location] <n>")
+		}
+		p := gs.GetPlayerSnap()
+		n := 0
+		for _, u := range p.Units {
+			if len(words) == 4 && u.Location != gamelogic.Location(words[2]) {
+				continue
+			}
+			n++
+		}
+		got = strconv.Itoa(n)
+	case "resources":
+		got = strconv.Itoa(gs.GetResources())
+	case "paused":
+		got = strconv.FormatBool(gs.IsPaused())
+	case "over":
+		got = strconv.FormatBool(gs.IsOver())
+	case "winner":
+		got = gs.GetWinner()
+	default:
+		return fmt.Errorf("can not expect %s", words[1])
+	}
+	if words[1] != "units" && len(words) > 3 {
+		return fmt.Errorf("usage: expect %s <value>", words[1])
+	}
+	if got != want {
+		return fmt.Errorf("expected %s %s, got %s", strings.Join(words[1:len(words)-1], " "), want, got)
+	}
+	return nil
+}
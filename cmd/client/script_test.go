@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func writeScript(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alice.peril")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScript(t *testing.T) {
+	path := writeScript(t, `# alice attacks
+login alice
+
+create 2
+  ready
+# wait for bob
+wait move 5s
+`)
+	s, err := loadScript(path)
+	if err != nil {
+		t.Fatalf("loadScript: %v", err)
+	}
+	if s.username != "alice" {
+		t.Errorf("username = %q, want alice", s.username)
+	}
+	want := []scriptLine{
+		{number: 4, words: []string{"create", "2"}},
+		{number: 5, words: []string{"ready"}},
+		{number: 7, words: []string{"wait", "move", "5s"}},
+	}
+	if len(s.lines) != len(want) {
+		t.Fatalf("lines = %v, want %v", s.lines, want)
+	}
+	for i, l := range s.lines {
+		if l.number != want[i].number || strings.Join(l.words, " ") != strings.Join(want[i].words, " ") {
+			t.Errorf("line %d = %v, want %v", i, l, want[i])
+		}
+	}
+}
+
+func TestLoadScriptRequiresLogin(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"# nothing\n",
+		"create 2\nlogin alice\n",
+		"login\n",
+		"login alice bob\n",
+	} {
+		if _, err := loadScript(writeScript(t, text)); err == nil {
+			t.Errorf("loadScript(%q) succeeded", text)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	s := newScript("test")
+	if err := s.wait([]string{"wait", "10ms"}); err != nil {
+		t.Errorf("wait 10ms: %v", err)
+	}
+	for _, words := range [][]string{
+		{"wait"},
+		{"wait", "lunch"},
+		{"wait", client.EventMove, "soon"},
+	} {
+		if err := s.wait(words); err == nil {
+			t.Errorf("%s succeeded", strings.Join(words, " "))
+		}
+	}
+
+	// A move that came before the wait counts, but only for one wait.
+	s.observe(client.EventMove, nil)
+	if err := s.wait([]string{"wait", client.EventMove, "10ms"}); err != nil {
+		t.Errorf("wait for an earlier move: %v", err)
+	}
+	if err := s.wait([]string{"wait", client.EventMove, "10ms"}); err == nil {
+		t.Error("two waits used up one move")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.observe(client.EventChat, nil)
+		s.observe(client.EventWar, nil)
+	}()
+	if err := s.wait([]string{"wait", client.EventWar, "5s"}); err != nil {
+		t.Errorf("wait for a later war: %v", err)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	gs := gamelogic.NewGameState("alice")
+	gs.Restore(gamelogic.Snapshot{
+		Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+			1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"},
+			2: {ID: 2, Rank: gamelogic.RankCavalry, Location: "europe"},
+			3: {ID: 3, Rank: gamelogic.RankInfantry, Location: "asia"},
+		}},
+		NextID:    4,
+		Resources: 5,
+	})
+
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"expect units 3", true},
+		{"expect units europe 2", true},
+		{"expect units africa 0", true},
+		{"expect units 2", false},
+		{"expect units asia 2", false},
+		{"expect units europe", false},
+		{"expect units a b c d e", false},
+		{"expect resources 5", true},
+		{"expect resources 4", false},
+		{"expect resources 5 6", false},
+		{"expect paused false", true},
+		{"expect over false", true},
+		{"expect over true", false},
+		{"expect winner alice", false},
+		{"expect luck 7", false},
+		{"expect units", false},
+	}
+	for _, tt := range tests {
+		err := expect(gs, strings.Fields(tt.line))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: passed = %v (%v), want %v", tt.line, ok, err, tt.ok)
+		}
+	}
+}
+
+// A game command that fails fails the script instead of only printing.
+func TestStepFailsOnGameCommandError(t *testing.T) {
+	c := client.New(nil, nil, gamelogic.NewGameState("alice"), client.Session{})
+	s := newScript("test")
+	inGame := true
+	for _, line := range []string{"dance", "move mars 1"} {
+		if err := s.step(c, nil, strings.Fields(line), &inGame); err == nil || err == errScriptQuit {
+			t.Errorf("%s: err = %v, want a failure", line, err)
+		}
+	}
+}
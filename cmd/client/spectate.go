@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// runSpectatorLobby lists games until the spectator picks one to watch.
+func runSpectatorLobby(c *client.Client) error {
+	l, err := c.EnterLobby()
+	if err != nil {
+		return err
+	}
+
+	watching := false
+	commands := gamelogic.NewRegistry("Spectator lobby commands:")
+	commands.Register(gamelogic.Command{
+		Name: "games",
+		Run: func(gamelogic.Args) error {
+			return l.List()
+		},
+	})
+	commands.Register(gamelogic.Command{
+		Name: "watch",
+		Args: []gamelogic.Arg{{Name: "gameID", Type: gamelogic.ArgGameID}},
+		Run: func(args gamelogic.Args) error {
+			if err := c.Spectate(args.String("gameID")); err != nil {
+				return err
+			}
+			watching = true
+			return nil
+		},
+	})
+	commands.Register(gamelogic.Command{
+		Name:    "quit",
+		Aliases: []string{"exit"},
+		Run: func(gamelogic.Args) error {
+			return errLeftLobby
+		},
+	})
+	commands.Register(gamelogic.Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Run: func(gamelogic.Args) error {
+			commands.PrintHelp()
+			return nil
+		},
+	})
+
+	gamelogic.SetCompleter(commands.Completer(gamelogic.CompletionValues))
+	commands.PrintHelp()
+	for !watching {
+		words, err := c.GetInput()
+		if err != nil {
+			return err
+		}
+		if words == nil && gamelogic.InputClosed() {
+			words = []string{"quit"}
+		}
+		err = commands.Run(words)
+		if errors.Is(err, errLeftLobby) {
+			return errLeftLobby
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/websocket"
+)
+
+const maxRequestBody = 1 << 16
+
+type gateway struct {
+	players *players
+}
+
+func (g *gateway) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", g.handleLogin)
+	mux.HandleFunc("POST /logout", g.authed(g.handleLogout))
+	mux.HandleFunc("POST /lobby", g.authed(g.handleLobby))
+	mux.HandleFunc("POST /ready", g.authed(g.handleReady))
+	mux.HandleFunc("POST /spawn", g.authed(g.inGame(g.handleSpawn)))
+	mux.HandleFunc("POST /move", g.authed(g.inGame(g.handleMove)))
+	mux.HandleFunc("POST /command", g.authed(g.inGame(g.handleCommand)))
+	mux.HandleFunc("GET /status", g.authed(g.handleStatus))
+	mux.HandleFunc("GET /events", g.authed(g.handleEvents))
+	return mux
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+}
+
+type loginResponse struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// lobbyRequest mirrors the terminal lobby commands. Results arrive on the
+// event stream, the same way the server answers terminal clients.
+type lobbyRequest struct {
+	Action string `json:"action"`
+	GameID string `json:"gameId"`
+	Size   int    `json:"size"`
+}
+
+type spawnRequest struct {
+	Location string `json:"location"`
+	Rank     string `json:"rank"`
+}
+
+type moveRequest struct {
+	Location string `json:"location"`
+	Units    []int  `json:"units"`
+}
+
+type commandRequest struct {
+	Command string `json:"command"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (g *gateway) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	p, err := g.players.login(req.Username)
+	var rejected *client.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		writeError(w, http.StatusConflict, err)
+		return
+	case errors.Is(err, client.ErrNoServer):
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, loginResponse{
+		Username: req.Username,
+		Token:    p.token,
+	})
+}
+
+func (g *gateway) handleLogout(w http.ResponseWriter, r *http.Request, p *player) {
+	g.players.logout(p)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *gateway) handleLobby(w http.ResponseWriter, r *http.Request, p *player) {
+	var req lobbyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if p.playing() {
+		writeError(w, http.StatusConflict, errors.New("already in a game"))
+		return
+	}
+	var err error
+	switch req.Action {
+	case routing.LobbyActionList:
+		err = p.lobby.List()
+	case routing.LobbyActionCreate:
+		err = p.lobby.Create(req.Size)
+	case routing.LobbyActionJoin:
+		err = p.lobby.Join(req.GameID)
+	case routing.LobbyActionAutoMatch:
+		err = p.lobby.AutoMatch(req.Size)
+	case routing.LobbyActionLeave:
+		err = p.lobby.Leave()
+	default:
+		err = fmt.Errorf("unknown lobby action: %q", req.Action)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleReady answers at once; the game's start is sent on the event stream
+// when every player is ready.
+func (g *gateway) handleReady(w http.ResponseWriter, r *http.Request, p *player) {
+	if err := p.ready(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// spawn and move go through the client's commands so they are validated
+// exactly like typed ones.
+func (g *gateway) handleSpawn(w http.ResponseWriter, r *http.Request, p *player) {
+	var req spawnRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	g.run(w, p, []string{"spawn", req.Location, req.Rank})
+}
+
+func (g *gateway) handleMove(w http.ResponseWriter, r *http.Request, p *player) {
+	var req moveRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	words := []string{"move", req.Location}
+	for _, id := range req.Units {
+		words = append(words, strconv.Itoa(id))
+	}
+	g.run(w, p, words)
+}
+
+func (g *gateway) handleCommand(w http.ResponseWriter, r *http.Request, p *player) {
+	var req commandRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	words := strings.Fields(req.Command)
+	if len(words) > 0 && (words[0] == "quit" || words[0] == "exit") {
+		writeError(w, http.StatusBadRequest, errors.New("use /logout to quit"))
+		return
+	}
+	g.run(w, p, words)
+}
+
+func (g *gateway) run(w http.ResponseWriter, p *player, words []string) {
+	if err := p.c.Run(words); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.state())
+}
+
+func (g *gateway) handleStatus(w http.ResponseWriter, r *http.Request, p *player) {
+	writeJSON(w, http.StatusOK, p.state())
+}
+
+// handleEvents streams the player's events over a WebSocket, one JSON object
+// per message, starting with the current state.
+func (g *gateway) handleEvents(w http.ResponseWriter, r *http.Request, p *player) {
+	ws, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("websocket upgrade failed: %v", err)
+		return
+	}
+	defer ws.Close()
+	stream := p.subscribe()
+	defer p.unsubscribe(stream)
+
+	// the browser sends nothing we need, but reading notices when it goes
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(e event) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("could not encode event: %v", err)
+			return true
+		}
+		return ws.WriteText(data) == nil
+	}
+	if !send(event{Type: "status", State: p.state()}) {
+		return
+	}
+	for {
+		select {
+		case e, ok := <-stream:
+			if !ok || !send(e) {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// authed finds the player from the bearer token, or from the token query
+// parameter since browsers can't set headers on a WebSocket.
+func (g *gateway) authed(next func(http.ResponseWriter, *http.Request, *player)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		p, ok := g.players.get(token)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, errors.New("log in first"))
+			return
+		}
+		next(w, r, p)
+	}
+}
+
+func (g *gateway) inGame(next func(http.ResponseWriter, *http.Request, *player)) func(http.ResponseWriter, *http.Request, *player) {
+	return func(w http.ResponseWriter, r *http.Request, p *player) {
+		if !p.playing() {
+			writeError(w, http.StatusConflict, errors.New("join a game and ready up first"))
+			return
+		}
+		next(w, r, p)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody)).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("could not write response: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to serve HTTP and WebSocket clients on")
+	static := flag.String("static", "", "directory of a web front end to serve at /")
+	flag.Parse()
+
+	g := &gateway{players: newPlayers(client.PlayerConnString)}
+	mux := g.routes()
+	if *static != "" {
+		mux.Handle("GET /", http.FileServer(http.Dir(*static)))
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Peril gateway listening on %s\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("could not serve: %v", err)
+	}
+	// everyone still logged in quits as if they had typed it
+	g.players.closeAll()
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// eventBuffer is how many events a slow stream may fall behind before it
+// starts missing them.
+const eventBuffer = 64
+
+// event is what the stream sends: the kind of message the client handled,
+// the message itself and the player's state after handling it.
+type event struct {
+	Type    string `json:"type"`
+	Message any    `json:"message,omitempty"`
+	State   *state `json:"state,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+const (
+	eventStarted = "started"
+	eventKicked  = "kicked"
+	eventOutput  = "output"
+)
+
+type state struct {
+	Username  string                     `json:"username"`
+	GameID    string                     `json:"gameId,omitempty"`
+	InGame    bool                       `json:"inGame"`
+	Resources int                        `json:"resources"`
+	Paused    bool                       `json:"paused"`
+	Over      bool                       `json:"over"`
+	Winner    string                     `json:"winner,omitempty"`
+	Units     []gamelogic.Unit           `json:"units"`
+	Enemies   map[gamelogic.Location]int `json:"enemies"`
+	Allies    []string                   `json:"allies"`
+}
+
+// player is one browser's seat in the game: its own AMQP connection and
+// client, exactly as a terminal player would have.
+type player struct {
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	journal *gamelogic.FileJournal
+	c       *client.Client
+	gs      *gamelogic.GameState
+	lobby   *client.Lobby
+	token   string
+
+	mu      *sync.Mutex
+	inGame  bool
+	readied bool
+	streams map[chan event]struct{}
+}
+
+// players holds every logged in player by session token.
+type players struct {
+	url     string
+	byToken map[string]*player
+	mu      *sync.Mutex
+}
+
+func newPlayers(url string) *players {
+	return &players{
+		url:     url,
+		byToken: map[string]*player{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+// login registers username with the server and puts the new player in the
+// lobby.
+func (ps *players) login(username string) (*player, error) {
+	connName := fmt.Sprintf("peril_gateway.%d.%d", os.Getpid(), time.Now().UnixNano())
+	conn, err := pubsub.DialNamed(ps.url, connName)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to RabbitMQ: %v", err)
+	}
+	sess, err := client.Register(conn, connName, username)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if conn, err = client.Connect(conn, connName, sess); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p, err := ps.start(conn, sess)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ps.mu.Lock()
+	ps.byToken[p.token] = p
+	ps.mu.Unlock()
+	log.Printf("%s logged in", username)
+	return p, nil
+}
+
+func (ps *players) start(conn *amqp.Connection, sess client.Session) (*player, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not open channel: %v", err)
+	}
+	gs := gamelogic.NewGameState(sess.Username)
+	journal, err := gamelogic.OpenJournal(sess.Username)
+	if err != nil {
+		return nil, err
+	}
+	gs.SetJournal(journal)
+
+	p := &player{
+		conn:    conn,
+		ch:      ch,
+		journal: journal,
+		gs:      gs,
+		token:   sess.Token,
+		mu:      &sync.Mutex{},
+		streams: map[chan event]struct{}{},
+	}
+	gs.SetOutput(output{p: p})
+	p.c = client.New(conn, ch, gs, sess)
+	p.c.Observer = func(kind string, msg any) {
+		p.publish(event{Type: kind, Message: msg})
+	}
+	p.c.OnKick = func(n routing.AdminNotice) {
+		p.publish(event{Type: eventKicked, Message: n})
+		// this runs on a consumer of the connection remove closes
+		go ps.remove(p)
+	}
+	if err := p.c.Start(); err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("subscribe failed: %v", err)
+	}
+	p.lobby, err = p.c.EnterLobby()
+	if err != nil {
+		p.c.Leave()
+		journal.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (ps *players) get(token string) (*player, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.byToken[token]
+	return p, ok
+}
+
+// logout autosaves and leaves like a terminal player quitting.
+func (ps *players) logout(p *player) {
+	p.c.Quit()
+	ps.remove(p)
+}
+
+func (ps *players) remove(p *player) {
+	ps.mu.Lock()
+	_, ok := ps.byToken[p.token]
+	delete(ps.byToken, p.token)
+	ps.mu.Unlock()
+	if !ok {
+		return
+	}
+	p.c.Leave()
+	p.closeStreams()
+	p.journal.Close()
+	p.conn.Close()
+	log.Printf("%s logged out", p.gs.GetUsername())
+}
+
+func (ps *players) closeAll() {
+	ps.mu.Lock()
+	all := []*player{}
+	for _, p := range ps.byToken {
+		all = append(all, p)
+	}
+	ps.mu.Unlock()
+	for _, p := range all {
+		ps.logout(p)
+	}
+}
+
+// ready waits in the background for the game to start, since that takes
+// until every other player is ready too.
+func (p *player) ready() error {
+	p.mu.Lock()
+	if p.inGame || p.readied {
+		p.mu.Unlock()
+		return errors.New("already ready")
+	}
+	p.readied = true
+	p.mu.Unlock()
+
+	go func() {
+		start, err := p.lobby.Ready()
+		if err == nil {
+			err = p.c.JoinGame(start)
+		}
+		p.mu.Lock()
+		p.readied = false
+		p.inGame = err == nil
+		p.mu.Unlock()
+		if err != nil {
+			p.publish(event{Type: eventStarted, Error: err.Error()})
+			return
+		}
+		p.publish(event{Type: eventStarted, Message: start})
+	}()
+	return nil
+}
+
+func (p *player) playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inGame
+}
+
+func (p *player) state() *state {
+	units := []gamelogic.Unit{}
+	for _, u := range p.gs.GetPlayerSnap().Units {
+		units = append(units, u)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	allies := []string{}
+	for _, a := range p.gs.AlliedForces() {
+		allies = append(allies, a.Username)
+	}
+	return &state{
+		Username:  p.gs.GetUsername(),
+		GameID:    p.c.GameID(),
+		InGame:    p.playing(),
+		Resources: p.gs.GetResources(),
+		Paused:    p.gs.IsPaused(),
+		Over:      p.gs.IsOver(),
+		Winner:    p.gs.GetWinner(),
+		Units:     units,
+		Enemies:   p.gs.KnownEnemies(),
+		Allies:    allies,
+	}
+}
+
+// subscribe opens a stream of the player's events. Streams that fall behind
+// drop events rather than hold up the client's consumers.
+func (p *player) subscribe() chan event {
+	stream := make(chan event, eventBuffer)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.streams[stream] = struct{}{}
+	return stream
+}
+
+func (p *player) unsubscribe(stream chan event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.streams[stream]; ok {
+		delete(p.streams, stream)
+		close(stream)
+	}
+}
+
+func (p *player) publish(e event) {
+	e.State = p.state()
+	p.send(e)
+}
+
+// send is publish without the state, for what is printed while the game
+// state may be locked.
+func (p *player) send(e event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for stream := range p.streams {
+		select {
+		case stream <- e:
+		default:
+		}
+	}
+}
+
+// output sends what the player's game state and client print to their
+// event streams, where a terminal player would have read it.
+type output struct {
+	p *player
+}
+
+func (o output) Write(b []byte) (int, error) {
+	o.p.send(event{Type: eventOutput, Message: string(b)})
+	return len(b), nil
+}
+
+func (p *player) closeStreams() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for stream := range p.streams {
+		delete(p.streams, stream)
+		close(stream)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// step is either a journal event or a server game log line, so both can be
+// walked through on one timeline.
+type step struct {
+	time  time.Time
+	event *gamelogic.Event
+	log   *routing.GameLog
+}
+
+func main() {
+	logsPath := flag.String("log", "", "server game.log to interleave with the journal")
+	stepping := flag.Bool("step", true, "wait for enter between steps")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <journal.jsonl>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	events, err := gamelogic.ReadJournal(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("could not read journal: %v", err)
+	}
+	if len(events) == 0 {
+		log.Fatalf("journal %s has no events", flag.Arg(0))
+	}
+
+	steps := []step{}
+	for i := range events {
+		steps = append(steps, step{time: events[i].Time, event: &events[i]})
+	}
+	if *logsPath != "" {
+		logs, err := gamelogic.ReadLogs(*logsPath)
+		if err != nil {
+			log.Fatalf("could not read game logs: %v", err)
+		}
+		for i := range logs {
+			steps = append(steps, step{time: logs[i].CurrentTime, log: &logs[i]})
+		}
+	}
+	// stable so journal order wins for events that share a timestamp
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].time.Before(steps[j].time)
+	})
+
+	username := events[0].Username
+	gs := gamelogic.NewGameState(username)
+	fmt.Printf("Replaying %d event(s) for %s\n", len(events), username)
+
+	input := bufio.NewScanner(os.Stdin)
+	for i, s := range steps {
+		fmt.Printf("---- step %d/%d ----\n", i+1, len(steps))
+		if s.log != nil {
+			fmt.Printf("%s [%s log] %s: %s\n", s.time.Format(time.RFC3339), s.log.Type, s.log.Username, s.log.Message)
+		} else {
+			gs.ApplyEvent(*s.event)
+			fmt.Printf("%s #%d %s\n", s.time.Format(time.RFC3339), s.event.Seq, describeEvent(*s.event))
+			printState(gs)
+		}
+
+		if !*stepping || i == len(steps)-1 {
+			continue
+		}
+		fmt.Print("[enter] next, q quit > ")
+		if !input.Scan() || strings.TrimSpace(input.Text()) == "q" {
+			return
+		}
+	}
+	fmt.Println("Replay complete.")
+}
+
+func describeEvent(e gamelogic.Event) string {
+	switch e.Type {
+	case gamelogic.EventUnitSpawned:
+		return fmt.Sprintf("spawned %s %d in %s", e.Unit.Rank, e.Unit.ID, e.Unit.Location)
+	case gamelogic.EventUnitMoved:
+		return fmt.Sprintf("moved %s %d to %s", e.Unit.Rank, e.Unit.ID, e.Unit.Location)
+	case gamelogic.EventUnitsDestroyed:
+		return fmt.Sprintf("lost all units in %s", e.Location)
+	case gamelogic.EventGamePaused:
+		if e.Paused {
+			return "game paused"
+		}
+		return "game resumed"
+	case gamelogic.EventStateRestored:
+		return "state restored from snapshot"
+	default:
+		return string(e.Type)
+	}
+}
+
+func printState(gs *gamelogic.GameState) {
+	s := gs.Snapshot()
+	ids := []int{}
+	for id := range s.Player.Units {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fmt.Printf("  paused: %v, units: %d\n", s.Paused, len(ids))
+	for _, id := range ids {
+		unit := s.Player.Units[id]
+		fmt.Printf("  * %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+	}
+}
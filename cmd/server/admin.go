@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/presence"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const kickGrace = 500 * time.Millisecond
+
+type admin struct {
+	registry *session.Registry
+	tracker  *presence.Tracker
+	lobby    *lobby.Lobby
+	world    *world.World
+	mgmt     *pubsub.Management
+	users    *playerUsers
+	ch       *amqp.Channel
+	// user is the broker user ch publishes as, which clients check notices
+	// came from
+	user string
+}
+
+// kick ends a player's session and removes them from the broker. With ban
+// set the name is also refused on every later registration.
+func (a *admin) kick(username, reason string, ban bool) error {
+	action := routing.AdminActionKick
+	if ban {
+		action = routing.AdminActionBan
+		if err := a.registry.Ban(username); err != nil {
+			return err
+		}
+		log.Printf("banned %s", username)
+	}
+
+	s, hadSession := a.registry.Kick(username)
+	gameID := ""
+	if army, ok := a.world.Army(username); ok {
+		gameID = army.GameID
+	}
+	if g, ok := a.lobby.Leave(username); ok {
+		gameID = g.GameID
+	}
+	a.world.Remove(username)
+	if p, ok := a.tracker.Leave(username); ok {
+		if err := publishPresence(a.ch, p); err != nil {
+			log.Printf("could not publish presence: %v", err)
+		}
+	}
+	err := pubsub.PublishJSONAs(a.ch, routing.ExchangePerilTopic, routing.AdminKey(username), a.user, routing.AdminNotice{
+		Action: action,
+		Reason: reason,
+	})
+	if err != nil {
+		return fmt.Errorf("could not notify %s: %v", username, err)
+	}
+	if !hadSession {
+		log.Printf("%s has no active session", username)
+	}
+
+	if a.mgmt == nil {
+		return errors.New("management API unavailable, queues were not removed")
+	}
+	// give the client a moment to show the notice before it's cut off,
+	// without holding up the REPL
+	time.AfterFunc(kickGrace, func() {
+		if err := a.disconnect(username, gameID, s, hadSession); err != nil {
+			log.Printf("could not disconnect %s: %v", username, err)
+		}
+		a.users.remove(username)
+	})
+	return nil
+}
+
+// disconnect closes a kicked player's connection and deletes whatever of
+// their queues are left behind.
+func (a *admin) disconnect(username, gameID string, s session.Session, hadSession bool) error {
+	// the player's own queues are exclusive to their connection, so closing
+	// it is what actually removes them
+	if hadSession && s.ConnectionName != "" {
+		n, err := a.mgmt.CloseConnections(s.ConnectionName, "kicked by server admin")
+		if err != nil {
+			return err
+		}
+		log.Printf("closed %d connection(s) for %s", n, username)
+	}
+	queues, err := a.mgmt.ListQueues()
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	for _, q := range queues {
+		declared[q.Name] = true
+	}
+	for _, name := range routing.PlayerQueues(gameID, username) {
+		if !declared[name] {
+			continue
+		}
+		if err := a.mgmt.DeleteQueue(name); err != nil {
+			log.Printf("could not delete queue %s: %v", name, err)
+			continue
+		}
+		log.Printf("deleted queue %s", name)
+	}
+	return nil
+}
+
+func (a *admin) unban(username string) error {
+	if err := a.registry.Unban(username); err != nil {
+		return err
+	}
+	log.Printf("unbanned %s", username)
+	return nil
+}
+
+func (a *admin) inspect(username string) error {
+	if p, ok := a.tracker.Get(username); ok {
+		state := "offline"
+		if p.Online {
+			state = "online"
+		}
+		fmt.Printf("%s is %s, last seen %s ago\n", username, state, time.Since(p.LastSeen).Round(time.Second))
+	}
+	army, ok := a.world.Army(username)
+	if !ok {
+		return fmt.Errorf("no known units for %s", username)
+	}
+	fmt.Printf("%s in game %s had %d unit(s) and %d resource(s) %s ago:\n", username, army.GameID, len(army.Player.Units), army.Resources, time.Since(army.UpdatedAt).Round(time.Second))
+	printUnits(army.Player.Units)
+	return nil
+}
+
+func (a *admin) broadcast(msg string) error {
+	err := pubsub.PublishJSON(a.ch, routing.ExchangePerilTopic, routing.AnnouncementsKey(), routing.Announcement{
+		Message: msg,
+		At:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("broadcast: %s", msg)
+	return nil
+}
+
+func (a *admin) queues() error {
+	if a.mgmt == nil {
+		return errors.New("management API unavailable")
+	}
+	queues, err := a.mgmt.ListQueues()
+	if err != nil {
+		return err
+	}
+	if len(queues) == 0 {
+		fmt.Println("No queues.")
+	}
+	for _, q := range queues {
+		kind := "transient"
+		if q.Durable {
+			kind = "durable"
+		}
+		fmt.Printf("* %s (%s): %d message(s), %d consumer(s)\n", q.Name, kind, q.Messages, q.Consumers)
+	}
+	return nil
+}
+
+func printUnits(units map[int]gamelogic.Unit) {
+	ids := []int{}
+	for id := range units {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		unit := units[id]
+		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// playerTopicPrefixes are the routing keys players may publish on
+// peril_topic. Everything else there, chat deliveries included, can only
+// come from the server.
+var playerTopicPrefixes = []string{
+	routing.PresencePrefix,
+	routing.LobbyPrefix,
+	routing.WarResultsPrefix,
+	routing.SpawnsPrefix,
+	routing.DiplomacyPrefix,
+	routing.ChatRequestsPrefix,
+	routing.GameLogSlug,
+}
+
+// serverQueues are the server's queues on peril_server, which players may
+// not read, bind or delete.
+var serverQueues = regexp.QuoteMeta(routing.ServerQueue("")) + ".+"
+
+// serverUser is the broker user this server created for itself.
+type serverUser struct {
+	name     string
+	password string
+}
+
+// setupServerUser creates a broker user only this server knows the password
+// of, so the broker can tell its messages from the players'. Each server gets
+// its own user, so several can start at once.
+func setupServerUser(mgmt *pubsub.Management) (serverUser, error) {
+	suffix := make([]byte, 4)
+	password := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return serverUser{}, err
+	}
+	if _, err := rand.Read(password); err != nil {
+		return serverUser{}, err
+	}
+	u := serverUser{
+		name:     routing.ServerUserPrefix + "." + hex.EncodeToString(suffix),
+		password: hex.EncodeToString(password),
+	}
+	if err := mgmt.PutUser(u.name, u.password); err != nil {
+		return serverUser{}, fmt.Errorf("could not create server user: %v", err)
+	}
+	if err := mgmt.SetPermissions(u.name, ".*", ".*", ".*"); err != nil {
+		mgmt.DeleteUser(u.name)
+		return serverUser{}, fmt.Errorf("could not set server user permissions: %v", err)
+	}
+	return u, nil
+}
+
+func (u serverUser) connString() string {
+	return fmt.Sprintf("amqp://%s:%s@localhost:5672/", url.PathEscape(u.name), url.PathEscape(u.password))
+}
+
+// setupRegistrationUser creates the broker user players connect as to
+// register. It may only call the server's RPCs and read the replies, so it
+// has none of the rights of guest, which is an administrator.
+func setupRegistrationUser(mgmt *pubsub.Management) error {
+	if err := mgmt.PutUser(routing.PlayerUser, routing.PlayerPassword); err != nil {
+		return fmt.Errorf("could not create player user: %v", err)
+	}
+	replies := fmt.Sprintf("^(%s)$", replyQueues)
+	write := fmt.Sprintf("^(%s|%s)$", regexp.QuoteMeta(routing.ExchangePerilRPC), replyQueues)
+	if err := mgmt.SetPermissions(routing.PlayerUser, replies, write, replies); err != nil {
+		return fmt.Errorf("could not set player permissions: %v", err)
+	}
+	return nil
+}
+
+// replyQueues are where RPC replies arrive: RabbitMQ's direct reply-to for
+// AMQP and the queues it makes for STOMP's /temp-queue.
+var replyQueues = regexp.QuoteMeta("amq.rabbitmq.reply-to") + `|amq\.gen-.+`
+
+// playerUsers gives every registered player a broker user of their own, so
+// the broker only lets them read what is meant for them. A user is removed
+// when its session ends, and every one that's left when the server exits.
+type playerUsers struct {
+	mgmt  *pubsub.Management
+	names map[string]bool
+	mu    *sync.Mutex
+}
+
+func newPlayerUsers(mgmt *pubsub.Management) *playerUsers {
+	return &playerUsers{
+		mgmt:  mgmt,
+		names: map[string]bool{},
+		mu:    &sync.Mutex{},
+	}
+}
+
+// create makes username's broker user with a new password and returns it.
+func (pu *playerUsers) create(username string) (string, error) {
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return "", err
+	}
+	name := routing.PlayerBrokerUser(username)
+	if err := pu.mgmt.PutUser(name, hex.EncodeToString(password)); err != nil {
+		return "", fmt.Errorf("could not create broker user for %s: %v", username, err)
+	}
+	pu.mu.Lock()
+	pu.names[username] = true
+	pu.mu.Unlock()
+	configure, write, read, topicWrite, topicRead := playerPermissions(username)
+	if err := pu.mgmt.SetPermissions(name, configure, write, read); err != nil {
+		pu.remove(username)
+		return "", fmt.Errorf("could not set permissions for %s: %v", username, err)
+	}
+	if err := pu.mgmt.SetTopicPermissions(name, routing.ExchangePerilTopic, topicWrite, topicRead); err != nil {
+		pu.remove(username)
+		return "", fmt.Errorf("could not set topic permissions for %s: %v", username, err)
+	}
+	return hex.EncodeToString(password), nil
+}
+
+func (pu *playerUsers) remove(username string) {
+	pu.mu.Lock()
+	delete(pu.names, username)
+	pu.mu.Unlock()
+	err := pu.mgmt.DeleteUser(routing.PlayerBrokerUser(username))
+	if err != nil && !errors.Is(err, pubsub.ErrNotFound) {
+		log.Printf("could not remove broker user for %s: %v", username, err)
+	}
+}
+
+func (pu *playerUsers) removeAll() {
+	pu.mu.Lock()
+	names := []string{}
+	for username := range pu.names {
+		names = append(names, username)
+	}
+	pu.mu.Unlock()
+	for _, username := range names {
+		pu.remove(username)
+	}
+}
+
+// playerPermissions lets a player declare and read only their own queues,
+// whose names all end in their username, and the war queue their game
+// shares. They may publish to every exchange but peril_direct, read from
+// every one but peril_server, and on peril_topic publish only their own
+// requests and bind only the keys meant for everyone or for them.
+func playerPermissions(username string) (configure, write, read, topicWrite, topicRead string) {
+	user := regexp.QuoteMeta(username)
+	queues := fmt.Sprintf(`(?!%s$)(.+\.%s|%s\.[^.]+|%s)`, serverQueues, user, regexp.QuoteMeta(routing.WarRecognitionsPrefix), replyQueues)
+	configure = fmt.Sprintf("^%s$", queues)
+	write = fmt.Sprintf("^(%s|%s|%s|%s|%s)$",
+		regexp.QuoteMeta(routing.ExchangePerilTopic),
+		regexp.QuoteMeta(routing.ExchangePerilServer),
+		regexp.QuoteMeta(routing.ExchangePerilRPC),
+		regexp.QuoteMeta(pubsub.DeadLetterExchange),
+		queues,
+	)
+	read = fmt.Sprintf("^(%s|%s|%s)$",
+		regexp.QuoteMeta(routing.ExchangePerilDirect),
+		regexp.QuoteMeta(routing.ExchangePerilTopic),
+		queues,
+	)
+	topicWrite = fmt.Sprintf(`^(%s)\..+$`, strings.Join(playerTopicPrefixes, "|"))
+	topicRead = "^(" + strings.Join([]string{
+		regexp.QuoteMeta(routing.PresenceEventBinding()),
+		regexp.QuoteMeta(routing.AnnouncementsKey()),
+		regexp.QuoteMeta(routing.ChatGlobalKey()),
+		regexp.QuoteMeta(routing.ChatAllianceKey(username)),
+		regexp.QuoteMeta(routing.ChatDirectKey(username)),
+		regexp.QuoteMeta(routing.AdminKey(username)),
+		anyGame(routing.ChatGameKey),
+		anyGame(routing.IncomeKey),
+		anyGame(routing.GameOverKey),
+		anyGame(routing.TurnsKey),
+		anyGame(routing.WarRecognitionsBinding),
+		anyGame(func(gameID string) string { return routing.VisibleMovesKey(gameID, username) }),
+		anyGame(func(gameID string) string { return routing.DiplomacyKey(gameID, username) }),
+		anyGame(func(gameID string) string { return routing.TurnsPlayerKey(gameID, username) }),
+	}, "|") + ")$"
+	return configure, write, read, topicWrite, topicRead
+}
+
+// anyGame matches the keys key makes for every game. Usernames can't hold a
+// '#', so it stands in for the game ID.
+func anyGame(key func(gameID string) string) string {
+	return strings.ReplaceAll(regexp.QuoteMeta(key("#")), "#", `[^.]+`)
+}
+
+// dialServer connects as the server's own user when the management API is
+// available, and returns that user's name. Otherwise it connects as guest,
+// and players can't register, since the server has no way to give them
+// broker users. The returned cleanup removes the server's user.
+func dialServer(mgmt *pubsub.Management, guestConnString string) (*amqp.Connection, string, func(), error) {
+	u, err := setupServerUser(mgmt)
+	if err != nil {
+		log.Printf("connecting as guest, players can't register: %v", err)
+		conn, err := amqp.Dial(guestConnString)
+		return conn, "guest", func() {}, err
+	}
+	conn, err := amqp.Dial(u.connString())
+	if err != nil {
+		mgmt.DeleteUser(u.name)
+		return nil, "", nil, err
+	}
+	if err := setupRegistrationUser(mgmt); err != nil {
+		log.Printf("players can't register: %v", err)
+	}
+	return conn, u.name, func() { mgmt.DeleteUser(u.name) }, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// The broker checks a binding's key against the topic read pattern, so a
+// player may bind what everyone gets and what is meant for them, but never
+// another player's private keys.
+func TestPlayerTopicRead(t *testing.T) {
+	_, _, _, _, topicRead := playerPermissions("bob")
+	read := regexp.MustCompile(topicRead)
+	gameID := routing.NewGameID()
+
+	for _, key := range []string{
+		routing.PresenceEventBinding(),
+		routing.AnnouncementsKey(),
+		routing.AdminKey("bob"),
+		routing.ChatGlobalKey(),
+		routing.ChatGameKey(gameID),
+		routing.ChatAllianceKey("bob"),
+		routing.ChatDirectKey("bob"),
+		routing.VisibleMovesKey(gameID, "bob"),
+		routing.WarRecognitionsBinding(gameID),
+		routing.IncomeKey(gameID),
+		routing.GameOverKey(gameID),
+		routing.DiplomacyKey(gameID, "bob"),
+		routing.TurnsKey(gameID),
+		routing.TurnsPlayerKey(gameID, "bob"),
+	} {
+		if !read.MatchString(key) {
+			t.Errorf("bob can't bind %q", key)
+		}
+	}
+
+	for _, key := range []string{
+		routing.AdminKey("alice"),
+		routing.ChatAllianceKey("alice"),
+		routing.ChatAllianceKey("*"),
+		routing.ChatDirectKey("alice"),
+		routing.ChatDirectKey("jimbob"),
+		routing.ChatPrefix + ".#",
+		routing.VisibleMovesKey(gameID, "alice"),
+		routing.VisibleMovesKey(gameID, "*"),
+		routing.VisibleMovesPrefix + ".#",
+		routing.DiplomacyKey(gameID, "alice"),
+		routing.TurnsPlayerKey(gameID, "alice"),
+		routing.WarRecognitionsKey(gameID, "alice"),
+		routing.GameLogBinding(),
+		"#",
+	} {
+		if read.MatchString(key) {
+			t.Errorf("bob can bind %q", key)
+		}
+	}
+}
+
+func TestPlayerTopicReadQuotesUsernames(t *testing.T) {
+	_, _, _, _, topicRead := playerPermissions("b+b")
+	read := regexp.MustCompile(topicRead)
+	if !read.MatchString(routing.ChatDirectKey("b+b")) {
+		t.Errorf("b+b can't bind their own direct chat")
+	}
+	if read.MatchString(routing.ChatDirectKey("bbb")) {
+		t.Errorf("b+b can bind bbb's direct chat")
+	}
+}
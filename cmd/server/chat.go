@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	maxChatLength = 280
+	// a player may send chatBurst messages in any chatWindow
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+type chatLimiter struct {
+	sent map[string][]time.Time
+	mu   *sync.Mutex
+}
+
+func newChatLimiter() *chatLimiter {
+	return &chatLimiter{
+		sent: map[string][]time.Time{},
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (c *chatLimiter) allow(username string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	recent := []time.Time{}
+	for _, t := range c.sent[username] {
+		if now.Sub(t) < chatWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= chatBurst {
+		c.sent[username] = recent
+		return false
+	}
+	c.sent[username] = append(recent, now)
+	return true
+}
+
+// handlerChat checks every chat request and delivers it. Players can't
+// publish chat deliveries themselves, so these checks can't be skipped.
+func handlerChat(registry *session.Registry, limiter *chatLimiter, w *world.World, ch *amqp.Channel) func(routing.ChatMessage) pubsub.Acktype {
+	return func(msg routing.ChatMessage) pubsub.Acktype {
+		if !registry.Validate(msg.Username, msg.Token) {
+			log.Printf("dropped chat from unregistered %q", msg.Username)
+			return pubsub.NackDiscard
+		}
+		msg.Token = ""
+		msg.Text = strings.TrimSpace(msg.Text)
+		msg.At = time.Now()
+
+		rejected := func(reason string) pubsub.Acktype {
+			err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.ChatDirectKey(msg.Username), routing.ChatMessage{
+				Username: "server",
+				Channel:  routing.ChatChannelDirect,
+				Text:     "your message was not sent: " + reason,
+				At:       msg.At,
+			})
+			if err != nil {
+				return pubsub.NackRequeue
+			}
+			return pubsub.Ack
+		}
+		switch {
+		case msg.Text == "":
+			return rejected("empty messages are not sent")
+		case len(msg.Text) > maxChatLength:
+			return rejected(fmt.Sprintf("messages are limited to %d characters", maxChatLength))
+		case !limiter.allow(msg.Username, msg.At):
+			return rejected(fmt.Sprintf("slow down, at most %d messages every %s", chatBurst, chatWindow))
+		}
+
+		keys := []string{}
+		logLine := fmt.Sprintf("[%s] %s", msg.Channel, msg.Text)
+		switch msg.Channel {
+		case routing.ChatChannelGlobal:
+			keys = append(keys, routing.ChatGlobalKey())
+		case routing.ChatChannelGame:
+			if a, ok := w.Army(msg.Username); !ok || msg.GameID == "" || a.GameID != msg.GameID {
+				return rejected(fmt.Sprintf("you are not playing game %s", msg.GameID))
+			}
+			keys = append(keys, routing.ChatGameKey(msg.GameID))
+		case routing.ChatChannelAlliance:
+			a, ok := w.Army(msg.Username)
+			if !ok {
+				return rejected("you are not playing a game")
+			}
+			// only the sender's allies, as the server knows them, hear it
+			allies := w.Alliances(a.GameID)[msg.Username]
+			to := []string{}
+			for _, username := range msg.To {
+				if slices.Contains(allies, username) {
+					to = append(to, username)
+				}
+			}
+			if len(to) == 0 {
+				return rejected("you have no allies to talk to")
+			}
+			msg.To = to
+			// the sender sees their own alliance messages too
+			keys = append(keys, routing.ChatAllianceKey(msg.Username))
+			for _, username := range to {
+				keys = append(keys, routing.ChatAllianceKey(username))
+			}
+		case routing.ChatChannelDirect:
+			if len(msg.To) != 1 {
+				return pubsub.NackDiscard
+			}
+			keys = append(keys, routing.ChatDirectKey(msg.To[0]))
+			logLine = fmt.Sprintf("[whisper to %s] %s", msg.To[0], msg.Text)
+		default:
+			log.Printf("dropped chat on unknown channel %q from %s", msg.Channel, msg.Username)
+			return pubsub.NackDiscard
+		}
+
+		for _, key := range keys {
+			if err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, key, msg); err != nil {
+				log.Printf("could not deliver chat from %s: %v", msg.Username, err)
+				return pubsub.NackRequeue
+			}
+		}
+		err := pubsub.PublishGob(ch, routing.ExchangePerilTopic, routing.GameLogKey(msg.GameID, msg.Username), routing.GameLog{
+			CurrentTime: msg.At,
+			Message:     logLine,
+			Username:    msg.Username,
+			GameID:      msg.GameID,
+			Type:        routing.LogTypeChat,
+			Severity:    routing.LogSeverityInfo,
+			Fields: map[string]string{
+				"channel": msg.Channel,
+				"to":      strings.Join(msg.To, ","),
+			},
+		})
+		if err != nil {
+			log.Printf("could not log chat from %s: %v", msg.Username, err)
+		}
+		return pubsub.Ack
+	}
+}
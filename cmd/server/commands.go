@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/presence"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/stats"
+)
+
+var errServerQuit = errors.New("quit")
+
+func newServerCommands(pauses *pauser, adm *admin, ref *referee, gameLobby *lobby.Lobby, tracker *presence.Tracker, st *stats.Stats) *gamelogic.Registry {
+	r := gamelogic.NewRegistry("Possible commands:")
+	pauseArgs := []gamelogic.Arg{{Name: "options", Optional: true, Variadic: true}}
+	r.Register(gamelogic.Command{
+		Name:    "pause",
+		Args:    pauseArgs,
+		Usage:   "[game <gameID> | player <username>] [for <duration>] [reason...]",
+		Example: "pause game 3fa2c1 for 2m maintenance",
+		Run: func(args gamelogic.Args) error {
+			return pauses.command(args.Words())
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:  "resume",
+		Args:  pauseArgs,
+		Usage: "[game <gameID> | player <username>]",
+		Run: func(args gamelogic.Args) error {
+			return pauses.command(args.Words())
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "games",
+		Run: func(gamelogic.Args) error {
+			printGames(gameLobby.Games())
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "players",
+		Aliases: []string{"who"},
+		Run: func(gamelogic.Args) error {
+			printPlayers(tracker)
+			return nil
+		},
+	})
+	playerArgs := []gamelogic.Arg{
+		{Name: "username", Type: gamelogic.ArgUsername},
+		{Name: "reason", Optional: true, Variadic: true},
+	}
+	r.Register(gamelogic.Command{
+		Name: "kick",
+		Args: playerArgs,
+		Run: func(args gamelogic.Args) error {
+			return adm.kick(args.String("username"), args.String("reason"), false)
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "ban",
+		Args: playerArgs,
+		Run: func(args gamelogic.Args) error {
+			return adm.kick(args.String("username"), args.String("reason"), true)
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "unban",
+		Args: []gamelogic.Arg{{Name: "username", Type: gamelogic.ArgUsername}},
+		Run: func(args gamelogic.Args) error {
+			return adm.unban(args.String("username"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "inspect",
+		Args: []gamelogic.Arg{{Name: "username", Type: gamelogic.ArgUsername}},
+		Run: func(args gamelogic.Args) error {
+			return adm.inspect(args.String("username"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "broadcast",
+		Args:    []gamelogic.Arg{{Name: "message", Variadic: true}},
+		Example: "broadcast server restarting in 5 minutes",
+		Run: func(args gamelogic.Args) error {
+			return adm.broadcast(args.String("message"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "queues",
+		Run: func(gamelogic.Args) error {
+			return adm.queues()
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "territory",
+		Args: []gamelogic.Arg{{Name: "gameID", Type: gamelogic.ArgGameID}},
+		Run: func(args gamelogic.Args) error {
+			return ref.territory(args.String("gameID"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "leaderboard",
+		Aliases: []string{"top"},
+		Args:    []gamelogic.Arg{{Name: "n", Type: gamelogic.ArgInt, Optional: true}},
+		Run: func(args gamelogic.Args) error {
+			gamelogic.PrintLeaderboard(os.Stdout, st.Leaderboard(args.Int("n")))
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "quit",
+		Aliases: []string{"exit"},
+		Run: func(gamelogic.Args) error {
+			return errServerQuit
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Run: func(gamelogic.Args) error {
+			r.PrintHelp()
+			return nil
+		},
+	})
+	return r
+}
+
+// serverCompletions offers the players the server knows about and the games
+// in the lobby.
+func serverCompletions(gameLobby *lobby.Lobby, tracker *presence.Tracker) func(gamelogic.ArgType) []string {
+	return func(t gamelogic.ArgType) []string {
+		values := []string{}
+		switch t {
+		case gamelogic.ArgUsername:
+			for _, p := range tracker.Players() {
+				values = append(values, p.Username)
+			}
+		case gamelogic.ArgGameID:
+			for _, g := range gameLobby.Games() {
+				values = append(values, g.GameID)
+			}
+		default:
+			return gamelogic.CompletionValues(t)
+		}
+		return values
+	}
+}
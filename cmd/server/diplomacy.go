@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// handlerDiplomacy keeps the server's record of alliances, which decides who
+// defends together and who wins together.
+func handlerDiplomacy(w *world.World, registry *session.Registry) func(routing.Diplomacy) pubsub.Acktype {
+	return func(d routing.Diplomacy) pubsub.Acktype {
+		if fromSpectator(registry, d.From, "a diplomacy message") {
+			return pubsub.NackDiscard
+		}
+		if err := w.Diplomacy(d); err != nil {
+			log.Printf("ignored diplomacy: %v", err)
+			return pubsub.NackDiscard
+		}
+		return pubsub.Ack
+	}
+}
+
+// handlerWarDeclarations passes a war on to the game with both armies and the
+// defender's allies as the server last saw them, not as the defender
+// remembers them. Every client in the game gets it, so it only carries the
+// units where the war is fought.
+func handlerWarDeclarations(w *world.World, registry *session.Registry, ch *amqp.Channel) func(gamelogic.RecognitionOfWar) pubsub.Acktype {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.Acktype {
+		defender := rw.Defender.Username
+		if fromSpectator(registry, defender, "a war") {
+			return pubsub.NackDiscard
+		}
+		d, ok := w.Army(defender)
+		if !ok {
+			log.Printf("ignored a war declared by %s, who is not in a game", defender)
+			return pubsub.NackDiscard
+		}
+		if rw.Attacker.Username == defender {
+			log.Printf("ignored a war %s declared on themselves", defender)
+			return pubsub.NackDiscard
+		}
+		a, ok := w.Army(rw.Attacker.Username)
+		if !ok || a.GameID != d.GameID {
+			log.Printf("ignored a war declared by %s on %s, who is not in their game", defender, rw.Attacker.Username)
+			return pubsub.NackDiscard
+		}
+		if w.Allied(d.GameID, defender, rw.Attacker.Username) {
+			log.Printf("ignored a war between allies %s and %s", defender, rw.Attacker.Username)
+			return pubsub.NackDiscard
+		}
+		// the war is fought with the armies the server knows of, which decide
+		// its result too
+		rw.Attacker, rw.Defender = a.Player, d.Player
+		loc := rw.Location()
+		if loc == "" {
+			log.Printf("ignored a war declared by %s on %s, whose units never met", defender, rw.Attacker.Username)
+			return pubsub.NackDiscard
+		}
+		rw.Allies = w.AlliedForces(d.GameID, defender)
+		rw = rw.At(loc)
+		w.DeclareWar(d.GameID, rw)
+		err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.WarRecognitionsKey(d.GameID, defender), rw)
+		if err != nil {
+			log.Printf("could not pass on a war declared by %s: %v", defender, err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+}
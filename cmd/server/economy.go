@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// economy keeps every player's balance in the world: it funds players when
+// their game starts, pays income in every running game and charges for
+// spawns. Each tick carries the new balances so everyone sees the standings.
+type economy struct {
+	ruleset gamelogic.Ruleset
+	ch      *amqp.Channel
+	world   *world.World
+	games   map[string]chan struct{}
+	paused  pauseState
+	mu      *sync.Mutex
+}
+
+// pauseState mirrors what the players have been told: the latest pause or
+// resume that applies to a player decides whether they are paused.
+type pauseState struct {
+	all     bool
+	games   map[string]bool
+	players map[string]bool
+}
+
+func newEconomy(ruleset gamelogic.Ruleset, ch *amqp.Channel, w *world.World) *economy {
+	return &economy{
+		ruleset: ruleset,
+		ch:      ch,
+		world:   w,
+		games:   map[string]chan struct{}{},
+		paused: pauseState{
+			games:   map[string]bool{},
+			players: map[string]bool{},
+		},
+		mu: &sync.Mutex{},
+	}
+}
+
+func (e *economy) start(game routing.GameSummary) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.games[game.GameID]; ok {
+		return
+	}
+	for _, username := range game.Players {
+		e.world.Fund(game.GameID, username, e.ruleset.StartingResources)
+	}
+	done := make(chan struct{})
+	e.games[game.GameID] = done
+	go e.run(game.GameID, done)
+}
+
+func (e *economy) stop(gameID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	done, ok := e.games[gameID]
+	if !ok {
+		return
+	}
+	close(done)
+	delete(e.games, gameID)
+}
+
+// setPaused records a pause or resume the pauser sent.
+func (e *economy) setPaused(ps routing.PlayingState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case ps.Target != "":
+		e.paused.players[ps.Target] = ps.IsPaused
+	case ps.GameID != "":
+		e.paused.games[ps.GameID] = ps.IsPaused
+		for username := range e.paused.players {
+			if a, ok := e.world.Army(username); ok && a.GameID == ps.GameID {
+				delete(e.paused.players, username)
+			}
+		}
+	default:
+		e.paused = pauseState{
+			all:     ps.IsPaused,
+			games:   map[string]bool{},
+			players: map[string]bool{},
+		}
+	}
+}
+
+func (e *economy) isPaused(gameID, username string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if paused, ok := e.paused.players[username]; ok {
+		return paused
+	}
+	if paused, ok := e.paused.games[gameID]; ok {
+		return paused
+	}
+	return e.paused.all
+}
+
+func (e *economy) running(gameID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.games[gameID]
+	return ok
+}
+
+func (e *economy) run(gameID string, done chan struct{}) {
+	ticker := time.NewTicker(e.ruleset.IncomeInterval)
+	defer ticker.Stop()
+	for tick := 1; ; tick++ {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		for _, a := range e.world.Armies(gameID) {
+			if e.isPaused(gameID, a.Player.Username) {
+				continue
+			}
+			e.world.Earn(a.Player.Username, e.ruleset.Income(a.Player))
+		}
+		err := pubsub.PublishJSON(e.ch, routing.ExchangePerilTopic, routing.IncomeKey(gameID), gamelogic.IncomeTick{
+			GameID:    gameID,
+			Tick:      tick,
+			Standings: e.world.Standings(gameID),
+		})
+		if err != nil {
+			log.Printf("could not pay income %d in game %s: %v", tick, gameID, err)
+		}
+	}
+}
+
+// spawn charges a player for a unit and returns their new balance.
+func (e *economy) spawn(req gamelogic.SpawnRequest) (int, error) {
+	if !e.running(req.GameID) {
+		return 0, fmt.Errorf("game %s is not running", req.GameID)
+	}
+	if e.isPaused(req.GameID, req.Username) {
+		return 0, fmt.Errorf("the game is paused, you can not spawn units")
+	}
+	cost, ok := e.ruleset.RankCosts[req.Rank]
+	if !ok {
+		return 0, fmt.Errorf("%s is not a unit rank", req.Rank)
+	}
+	if !slices.Contains(gamelogic.Locations(), req.Location) {
+		return 0, fmt.Errorf("%s is not a location", req.Location)
+	}
+	return e.world.Buy(req.GameID, req.Username, req.Rank, cost)
+}
+
+func handlerSpawns(e *economy, registry *session.Registry) func(gamelogic.SpawnRequest) gamelogic.SpawnResponse {
+	return func(req gamelogic.SpawnRequest) gamelogic.SpawnResponse {
+		if !registry.Validate(req.Username, req.Token) {
+			return gamelogic.SpawnResponse{Error: "register first"}
+		}
+		if fromSpectator(registry, req.Username, "a spawn") {
+			return gamelogic.SpawnResponse{Error: "spectators can not spawn units"}
+		}
+		balance, err := e.spawn(req)
+		if err != nil {
+			return gamelogic.SpawnResponse{Resources: balance, Error: err.Error()}
+		}
+		return gamelogic.SpawnResponse{Resources: balance}
+	}
+}
+
+// handlerResourceReports only takes the units from a report. The balance in
+// it is the client's view and the server's own count always wins.
+func handlerResourceReports(w *world.World, registry *session.Registry) func(gamelogic.ResourceReport) pubsub.Acktype {
+	return func(r gamelogic.ResourceReport) pubsub.Acktype {
+		if fromSpectator(registry, r.Player.Username, "a resource report") {
+			return pubsub.NackDiscard
+		}
+		w.Update(r.GameID, r.Player)
+		return pubsub.Ack
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// handlerArmyMoves forwards every move to the other players in the game,
+// showing each of them only the units in or next to locations they occupy.
+func handlerArmyMoves(w *world.World, registry *session.Registry, ch *amqp.Channel) func(gamelogic.ArmyMove) pubsub.Acktype {
+	return func(mv gamelogic.ArmyMove) pubsub.Acktype {
+		if fromSpectator(registry, mv.Player.Username, "a move") {
+			return pubsub.NackDiscard
+		}
+		w.Update(mv.GameID, mv.Player)
+		for _, a := range w.Armies(mv.GameID) {
+			if a.Player.Username == mv.Player.Username {
+				continue
+			}
+			seen, ok := mv.FilterFor(a.Player)
+			if !ok {
+				continue
+			}
+			err := pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.VisibleMovesKey(mv.GameID, a.Player.Username), seen)
+			if err != nil {
+				log.Printf("could not forward a move from %s: %v", mv.Player.Username, err)
+				return pubsub.NackRequeue
+			}
+		}
+		return pubsub.Ack
+	}
+}
+
+func handlerArmyReports(w *world.World, registry *session.Registry) func(gamelogic.ArmyReport) pubsub.Acktype {
+	return func(r gamelogic.ArmyReport) pubsub.Acktype {
+		if fromSpectator(registry, r.Player.Username, "an army report") {
+			return pubsub.NackDiscard
+		}
+		w.Update(r.GameID, r.Player)
+		return pubsub.Ack
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func handlerLobby(l *lobby.Lobby, registry *session.Registry, ref *referee, ch *amqp.Channel) func(routing.LobbyRequest) pubsub.Acktype {
+	return func(req routing.LobbyRequest) pubsub.Acktype {
+		if !registry.Validate(req.Username, req.Token) {
+			log.Printf("dropped lobby request from unregistered %q", req.Username)
+			return pubsub.NackDiscard
+		}
+
+		// spectators can list every game, started ones included, but never
+		// take a seat in one
+		spectator := registry.IsSpectator(req.Username)
+		games := l.OpenGames
+		if spectator {
+			games = l.Games
+		}
+		if spectator && takesSeat(req.Action) {
+			if err := sendLobbyError(ch, req.Username, errors.New("spectators can't play")); err != nil {
+				log.Printf("could not send lobby update: %v", err)
+				return pubsub.NackRequeue
+			}
+			return pubsub.Ack
+		}
+
+		var err error
+		switch req.Action {
+		case routing.LobbyActionAnnounce:
+			if !spectator {
+				l.Announce(req.Username)
+			}
+			log.Printf("%s entered the lobby", req.Username)
+			err = sendLobbyUpdate(ch, req.Username, routing.LobbyUpdate{
+				Event: routing.LobbyEventGames,
+				Games: games(),
+			})
+		case routing.LobbyActionList:
+			err = sendLobbyUpdate(ch, req.Username, routing.LobbyUpdate{
+				Event: routing.LobbyEventGames,
+				Games: games(),
+			})
+		case routing.LobbyActionCreate, routing.LobbyActionJoin, routing.LobbyActionAutoMatch:
+			var game routing.GameSummary
+			var joinErr error
+			switch req.Action {
+			case routing.LobbyActionCreate:
+				game, joinErr = l.Create(req.Username, req.Size)
+			case routing.LobbyActionJoin:
+				game, joinErr = l.Join(req.Username, req.GameID)
+			default:
+				game, joinErr = l.AutoMatch(req.Username, req.Size)
+			}
+			if joinErr != nil {
+				err = sendLobbyError(ch, req.Username, joinErr)
+				break
+			}
+			log.Printf("%s joined game %s (%d/%d)", req.Username, game.GameID, len(game.Players), game.Size)
+			event := routing.LobbyEventJoined
+			if len(game.Players) == game.Size {
+				event = routing.LobbyEventReadyCheck
+			}
+			err = broadcastLobbyUpdate(ch, game, routing.LobbyUpdate{Event: event, Game: game})
+		case routing.LobbyActionReady:
+			game, started, readyErr := l.Ready(req.Username)
+			if readyErr != nil {
+				err = sendLobbyError(ch, req.Username, readyErr)
+				break
+			}
+			if !started {
+				err = broadcastLobbyUpdate(ch, game, routing.LobbyUpdate{Event: routing.LobbyEventReadyCheck, Game: game})
+				break
+			}
+			err = startGame(ch, l, ref, game)
+		case routing.LobbyActionLeave:
+			game, ok := l.Leave(req.Username)
+			log.Printf("%s left the lobby", req.Username)
+			if ok {
+				err = broadcastLobbyUpdate(ch, game, routing.LobbyUpdate{Event: routing.LobbyEventLeft, Game: game})
+			}
+		default:
+			err = sendLobbyError(ch, req.Username, fmt.Errorf("unknown lobby action: %s", req.Action))
+		}
+
+		if err != nil {
+			log.Printf("could not send lobby update: %v", err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+}
+
+func takesSeat(action string) bool {
+	switch action {
+	case routing.LobbyActionCreate, routing.LobbyActionJoin, routing.LobbyActionAutoMatch, routing.LobbyActionReady:
+		return true
+	}
+	return false
+}
+
+// startGame broadcasts the initial PlayingState with the roster on the game's
+// pause key, which every player bound before sending their ready. If that
+// fails the game goes back to waiting, so the requeued ready can start it.
+func startGame(ch *amqp.Channel, l *lobby.Lobby, ref *referee, game routing.GameSummary) error {
+	log.Printf("starting game %s with %v", game.GameID, game.Players)
+	ps := routing.PlayingState{
+		IsPaused: false,
+		GameID:   game.GameID,
+		Roster:   game.Players,
+	}
+	if cfg := ref.clocks.cfg; cfg.enabled {
+		ps.TurnMode = true
+		ps.TurnLength = cfg.length
+		ps.OrderLimit = cfg.orderLimit
+	}
+	err := pubsub.PublishJSON(ch, routing.ExchangePerilDirect, routing.PauseGameKey(game.GameID), ps)
+	if err != nil {
+		l.CancelStart(game.GameID)
+		return err
+	}
+	ref.start(game)
+	return broadcastLobbyUpdate(ch, game, routing.LobbyUpdate{Event: routing.LobbyEventStarted, Game: game})
+}
+
+func broadcastLobbyUpdate(ch *amqp.Channel, game routing.GameSummary, update routing.LobbyUpdate) error {
+	for _, username := range game.Players {
+		if err := sendLobbyUpdate(ch, username, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendLobbyUpdate(ch *amqp.Channel, username string, update routing.LobbyUpdate) error {
+	return pubsub.PublishJSON(ch, routing.ExchangePerilDirect, routing.LobbyUpdatesKey(username), update)
+}
+
+func sendLobbyError(ch *amqp.Channel, username string, err error) error {
+	return sendLobbyUpdate(ch, username, routing.LobbyUpdate{
+		Event: routing.LobbyEventError,
+		Error: err.Error(),
+	})
+}
+
+func printGames(games []routing.GameSummary) {
+	if len(games) == 0 {
+		fmt.Println("No games.")
+		return
+	}
+	for _, g := range games {
+		state := "open"
+		if g.Started {
+			state = "started"
+		}
+		fmt.Printf("* %s (%s): %d/%d players %v, ready %v\n", g.GameID, state, len(g.Players), g.Size, g.Players, g.Ready)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/presence"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type pauseCommand struct {
+	gameID   string
+	player   string
+	duration time.Duration
+	reason   string
+}
+
+// parsePauseCommand reads
+// pause|resume [game <gameID> | player <username>] [for <duration>] [reason...]
+func parsePauseCommand(words []string) (pauseCommand, error) {
+	pc := pauseCommand{}
+	args := words[1:]
+	for len(args) > 0 {
+		switch args[0] {
+		case "game", "player":
+			if len(args) < 2 {
+				return pauseCommand{}, fmt.Errorf("usage: %s %s <name>", words[0], args[0])
+			}
+			if pc.gameID != "" || pc.player != "" {
+				return pauseCommand{}, fmt.Errorf("target either a game or a player, not both")
+			}
+			if args[0] == "game" {
+				if err := routing.ValidateGameID(args[1]); err != nil {
+					return pauseCommand{}, err
+				}
+				pc.gameID = args[1]
+			} else {
+				pc.player = args[1]
+			}
+			args = args[2:]
+		case "for":
+			if len(args) < 2 {
+				return pauseCommand{}, fmt.Errorf("usage: %s for <duration>", words[0])
+			}
+			d, err := time.ParseDuration(args[1])
+			if err != nil || d <= 0 {
+				return pauseCommand{}, fmt.Errorf("error: %s is not a valid duration", args[1])
+			}
+			pc.duration = d
+			args = args[2:]
+		default:
+			pc.reason = strings.Join(args, " ")
+			args = nil
+		}
+	}
+	return pc, nil
+}
+
+// pauseScope is who a pause or resume applies to: everyone, one game, or
+// one player in a game.
+type pauseScope struct {
+	key    string
+	target string
+}
+
+// covers reports whether a command for s supersedes one for other. A global
+// command covers every game and a game's command covers its players.
+func (s pauseScope) covers(other pauseScope) bool {
+	switch {
+	case s.key == routing.PauseKey && s.target == "":
+		return true
+	case s.target == "":
+		return s.key == other.key
+	default:
+		return s == other
+	}
+}
+
+// pauser publishes pauses and schedules the resume of timed ones.
+type pauser struct {
+	ch      *amqp.Channel
+	tracker *presence.Tracker
+	econ    *economy
+	timers  map[pauseScope]*time.Timer
+	mu      *sync.Mutex
+}
+
+func newPauser(ch *amqp.Channel, tracker *presence.Tracker, econ *economy) *pauser {
+	return &pauser{
+		ch:      ch,
+		tracker: tracker,
+		econ:    econ,
+		timers:  map[pauseScope]*time.Timer{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (p *pauser) command(words []string) error {
+	pc, err := parsePauseCommand(words)
+	if err != nil {
+		return err
+	}
+	ps := routing.PlayingState{
+		IsPaused: words[0] == "pause",
+		Reason:   pc.reason,
+		GameID:   pc.gameID,
+		Target:   pc.player,
+	}
+	key := routing.PauseKey
+	switch {
+	case pc.gameID != "":
+		key = routing.PauseGameKey(pc.gameID)
+	case pc.player != "":
+		player, ok := p.tracker.Get(pc.player)
+		if !ok || !player.Online || player.GameID == "" {
+			return fmt.Errorf("%s is not playing a game", pc.player)
+		}
+		ps.GameID = player.GameID
+		key = routing.PauseGameKey(player.GameID)
+	}
+	if pc.duration > 0 {
+		if !ps.IsPaused {
+			return fmt.Errorf("only pauses can be timed")
+		}
+		ps.ResumeAt = time.Now().Add(pc.duration)
+	}
+	return p.publish(key, ps)
+}
+
+func (p *pauser) publish(key string, ps routing.PlayingState) error {
+	scope := pauseScope{key: key, target: ps.Target}
+	p.mu.Lock()
+	// an auto-resume left armed under a wider command would undo it later
+	for other, t := range p.timers {
+		if scope.covers(other) {
+			t.Stop()
+			delete(p.timers, other)
+		}
+	}
+	if ps.IsPaused && !ps.ResumeAt.IsZero() {
+		resume := routing.PlayingState{
+			IsPaused: false,
+			Reason:   "the timed pause is over",
+			GameID:   ps.GameID,
+			Target:   ps.Target,
+		}
+		p.timers[scope] = time.AfterFunc(time.Until(ps.ResumeAt), func() {
+			if err := p.publish(key, resume); err != nil {
+				log.Printf("could not send auto-resume: %v", err)
+			}
+		})
+	}
+	p.mu.Unlock()
+
+	verb := "resume"
+	if ps.IsPaused {
+		verb = "pause"
+	}
+	target := "everyone"
+	if ps.Target != "" {
+		target = ps.Target
+	} else if ps.GameID != "" {
+		target = "game " + ps.GameID
+	}
+	log.Printf("sending %s message to %s on %s", verb, target, key)
+	if err := pubsub.PublishJSON(p.ch, routing.ExchangePerilDirect, key, ps); err != nil {
+		return err
+	}
+	// nobody earns income while they are paused
+	p.econ.setPaused(ps)
+	return nil
+}
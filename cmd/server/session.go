@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/presence"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const heartbeatInterval = 5 * time.Second
+
+// a player is shown offline after one missed heartbeat, and their session
+// survives two
+const (
+	presenceTimeout = 2 * heartbeatInterval
+	sessionTTL      = 3 * heartbeatInterval
+)
+
+func handlerRegister(registry *session.Registry, users *playerUsers) func(routing.RegisterRequest) routing.RegisterResponse {
+	return func(req routing.RegisterRequest) routing.RegisterResponse {
+		if err := routing.ValidateUsername(req.Username); err != nil {
+			return routing.RegisterResponse{Error: err.Error()}
+		}
+		s, err := registry.Register(req.Username, req.ConnectionName, req.Spectator)
+		if err != nil {
+			log.Printf("rejected registration: %v", err)
+			return routing.RegisterResponse{Error: err.Error()}
+		}
+		resp := routing.RegisterResponse{
+			Token:             s.Token,
+			HeartbeatInterval: heartbeatInterval,
+		}
+		if s.Spectator {
+			log.Printf("registered spectator %s", s.Username)
+			return resp
+		}
+		password, err := users.create(s.Username)
+		if err != nil {
+			log.Printf("rejected registration: %v", err)
+			_ = registry.Unregister(s.Username, s.Token)
+			return routing.RegisterResponse{Error: "the server could not log you in to the broker"}
+		}
+		resp.BrokerUser = routing.PlayerBrokerUser(s.Username)
+		resp.BrokerPassword = password
+		log.Printf("registered %s", s.Username)
+		return resp
+	}
+}
+
+func handlerHeartbeat(registry *session.Registry, users *playerUsers, tracker *presence.Tracker, l *lobby.Lobby, ch *amqp.Channel) func(routing.Heartbeat) pubsub.Acktype {
+	return func(hb routing.Heartbeat) pubsub.Acktype {
+		if err := registry.Heartbeat(hb.Username, hb.Token); err != nil {
+			return pubsub.NackDiscard
+		}
+		if hb.Leaving {
+			_ = registry.Unregister(hb.Username, hb.Token)
+			users.remove(hb.Username)
+			l.Leave(hb.Username)
+			if p, ok := tracker.Leave(hb.Username); ok {
+				if err := publishPresence(ch, p); err != nil {
+					return pubsub.NackRequeue
+				}
+			}
+			return pubsub.Ack
+		}
+		if tracker.Beat(hb.Username, hb.GameID, time.Now()) {
+			p, _ := tracker.Get(hb.Username)
+			if err := publishPresence(ch, p); err != nil {
+				return pubsub.NackRequeue
+			}
+		}
+		return pubsub.Ack
+	}
+}
+
+// watchSessions marks players offline after missed heartbeats, then reclaims
+// their names, broker users and lobby seats once the session TTL runs out.
+func watchSessions(registry *session.Registry, users *playerUsers, tracker *presence.Tracker, l *lobby.Lobby, ch *amqp.Channel) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, p := range tracker.Sweep(now) {
+			if err := publishPresence(ch, p); err != nil {
+				log.Printf("could not publish presence: %v", err)
+			}
+		}
+		for _, username := range registry.Expire(now) {
+			users.remove(username)
+			l.Leave(username)
+			log.Printf("session for %s expired, name reclaimed", username)
+		}
+	}
+}
+
+func publishPresence(ch *amqp.Channel, p presence.Player) error {
+	if p.Online {
+		log.Printf("%s is online", p.Username)
+	} else {
+		log.Printf("%s went offline", p.Username)
+	}
+	return pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.PresenceEventKey(p.Username), routing.PresenceEvent{
+		Username: p.Username,
+		GameID:   p.GameID,
+		Online:   p.Online,
+		At:       p.LastSeen,
+	})
+}
+
+func printPlayers(tracker *presence.Tracker) {
+	players := tracker.Players()
+	if len(players) == 0 {
+		fmt.Println("No players have connected.")
+		return
+	}
+	now := time.Now()
+	for _, p := range players {
+		state := "offline"
+		if p.Online {
+			state = "online"
+		}
+		game := p.GameID
+		if game == "" {
+			game = "lobby"
+		}
+		fmt.Printf("* %s (%s) in %s, last seen %s ago\n", p.Username, state, game, now.Sub(p.LastSeen).Round(time.Second))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+)
+
+// spectatorQueues are the queues a spectator's connection declares: its own
+// copies of a game's traffic and the queues every registered name follows.
+// The MQTT bridge connects as a spectator too.
+const spectatorQueues = `(spectate|bridge|presence_events|announcements|admin|lobby_updates)\..+`
+
+// setupSpectatorUser lets spectators connect as their own broker user, which
+// may read its own queues and publish only heartbeats, lobby requests and
+// RPCs. Moves, wars, logs, pauses and notices published by a spectator are
+// refused by the broker before anyone ever sees them.
+func setupSpectatorUser(mgmt *pubsub.Management, password string) error {
+	if err := mgmt.PutUser(routing.SpectatorUser, password); err != nil {
+		return fmt.Errorf("could not create spectator user: %v", err)
+	}
+	resources := fmt.Sprintf("^(%s|%s|%s)$", routing.ExchangePerilRPC, routing.ExchangePerilTopic, spectatorQueues)
+	// spectators see every army, so they may read what players can't
+	readable := fmt.Sprintf("^(%s|%s|%s|%s)$", routing.ExchangePerilDirect, routing.ExchangePerilTopic, routing.ExchangePerilServer, spectatorQueues)
+	if err := mgmt.SetPermissions(routing.SpectatorUser, "^"+spectatorQueues+"$", resources, readable); err != nil {
+		return fmt.Errorf("could not set spectator permissions: %v", err)
+	}
+	publishable := fmt.Sprintf(`^(%s|%s)\.[^.]+$`, routing.PresencePrefix, routing.LobbyPrefix)
+	if err := mgmt.SetTopicPermissions(routing.SpectatorUser, routing.ExchangePerilTopic, publishable, ".*"); err != nil {
+		return fmt.Errorf("could not set spectator topic permissions: %v", err)
+	}
+	return nil
+}
+
+// removeSpectatorUser deletes the spectator user a previous run may have
+// left, so its password stops working when spectators are off.
+func removeSpectatorUser(mgmt *pubsub.Management) error {
+	err := mgmt.DeleteUser(routing.SpectatorUser)
+	if err != nil && !errors.Is(err, pubsub.ErrNotFound) {
+		return fmt.Errorf("could not remove spectator user: %v", err)
+	}
+	return nil
+}
+
+// handlerSpectate answers a spectator with the game and every army the
+// server knows of in it.
+func handlerSpectate(registry *session.Registry, l *lobby.Lobby, w *world.World) func(gamelogic.SpectateRequest) gamelogic.SpectateResponse {
+	return func(req gamelogic.SpectateRequest) gamelogic.SpectateResponse {
+		if !registry.Validate(req.Username, req.Token) {
+			return gamelogic.SpectateResponse{Error: "register first"}
+		}
+		if !registry.IsSpectator(req.Username) {
+			return gamelogic.SpectateResponse{Error: "only spectators can watch games"}
+		}
+		game, ok := l.Game(req.GameID)
+		if !ok {
+			return gamelogic.SpectateResponse{Error: fmt.Sprintf("no game %s", req.GameID)}
+		}
+		if !game.Started {
+			return gamelogic.SpectateResponse{Error: fmt.Sprintf("game %s has not started", req.GameID)}
+		}
+		armies := []gamelogic.Player{}
+		for _, a := range w.Armies(req.GameID) {
+			armies = append(armies, a.Player)
+		}
+		log.Printf("%s is watching game %s", req.Username, req.GameID)
+		return gamelogic.SpectateResponse{Game: game, Armies: armies}
+	}
+}
+
+// fromSpectator drops a message that claims to be a spectator's move or
+// army, in case one reaches us despite the broker's permissions.
+func fromSpectator(registry *session.Registry, username, what string) bool {
+	if !registry.IsSpectator(username) {
+		return false
+	}
+	log.Printf("dropped %s from spectator %s", what, username)
+	return true
+}
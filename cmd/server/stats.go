@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/stats"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+)
+
+const statsFile = "stats.json"
+
+// handlerWarResults records the result of a war the server passed on once
+// its attacker reports fighting it. The report only says which war is over:
+// who won and what it cost is worked out from the armies the server passed
+// on.
+func handlerWarResults(st *stats.Stats, registry *session.Registry, w *world.World) func(gamelogic.WarResultReport) pubsub.Acktype {
+	return func(report gamelogic.WarResultReport) pubsub.Acktype {
+		if !registry.Validate(report.Username, report.Token) {
+			log.Printf("dropped war result from %s without a session", report.Username)
+			return pubsub.NackDiscard
+		}
+		reported := report.Result
+		if reported.Attacker != report.Username {
+			log.Printf("dropped war result from %s for a war %s fought", report.Username, reported.Attacker)
+			return pubsub.NackDiscard
+		}
+		if fromSpectator(registry, report.Username, "a war result") {
+			return pubsub.NackDiscard
+		}
+		r, err := w.WarResult(reported.GameID, reported.Attacker, reported.Defender, reported.Location)
+		if err != nil {
+			log.Printf("dropped war result: %v", err)
+			return pubsub.NackDiscard
+		}
+		if r.Winner != reported.Winner {
+			log.Printf("%s reported %q won the war in %s, recording %q", report.Username, reported.Winner, r.Location, r.Winner)
+		}
+		if err := st.RecordWar(r); err != nil {
+			log.Printf("could not record war: %v", err)
+			return pubsub.NackRequeue
+		}
+		w.SettleWar(r)
+		return pubsub.Ack
+	}
+}
+
+func handlerLeaderboard(st *stats.Stats) func(gamelogic.LeaderboardRequest) gamelogic.LeaderboardResponse {
+	return func(req gamelogic.LeaderboardRequest) gamelogic.LeaderboardResponse {
+		return gamelogic.LeaderboardResponse{Players: st.Leaderboard(req.Limit)}
+	}
+}
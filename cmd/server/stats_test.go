@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/stats"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+)
+
+// The attacker only reports that a war is over. Who won is worked out from
+// the armies the server passed on, whatever the report claims.
+func TestHandlerWarResults(t *testing.T) {
+	registry := session.NewRegistry(time.Minute)
+	alice, err := registry.Register("alice", "conn-alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := registry.Register("bob", "conn-bob", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := stats.Load(filepath.Join(t.TempDir(), "stats.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := world.New()
+	attacker := gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{1: unit(1, "europe")}}
+	defender := gamelogic.Player{Username: "bob", Units: map[int]gamelogic.Unit{
+		1: {ID: 1, Rank: gamelogic.RankArtillery, Location: "europe"},
+	}}
+	w.Update("g1", attacker)
+	w.Update("g1", defender)
+	w.DeclareWar("g1", gamelogic.RecognitionOfWar{Attacker: attacker, Defender: defender})
+	handle := handlerWarResults(st, registry, w)
+
+	// alice claims the win that was bob's
+	claim := gamelogic.WarResult{
+		GameID:         "g1",
+		Attacker:       "alice",
+		Defender:       "bob",
+		Winner:         "alice",
+		Location:       "europe",
+		DefenderLosses: 1,
+	}
+	tests := []struct {
+		name   string
+		report gamelogic.WarResultReport
+		ack    pubsub.Acktype
+	}{
+		{"no token", gamelogic.WarResultReport{Username: "alice", Result: claim}, pubsub.NackDiscard},
+		{"someone else's token", gamelogic.WarResultReport{Username: "alice", Token: bob.Token, Result: claim}, pubsub.NackDiscard},
+		{"not the attacker", gamelogic.WarResultReport{Username: "bob", Token: bob.Token, Result: claim}, pubsub.NackDiscard},
+		{"the attacker", gamelogic.WarResultReport{Username: "alice", Token: alice.Token, Result: claim}, pubsub.Ack},
+		{"again", gamelogic.WarResultReport{Username: "alice", Token: alice.Token, Result: claim}, pubsub.NackDiscard},
+	}
+	for _, tt := range tests {
+		if ack := handle(tt.report); ack != tt.ack {
+			t.Errorf("%s: ack = %v, want %v", tt.name, ack, tt.ack)
+		}
+	}
+
+	records := map[string]gamelogic.PlayerStats{}
+	for _, p := range st.Leaderboard(0) {
+		records[p.Username] = p
+	}
+	if a := records["alice"]; a.Wins != 0 || a.Losses != 1 || a.UnitsLost != 1 || a.UnitsKilled != 0 {
+		t.Errorf("alice's record = %+v, want one loss of one unit", a)
+	}
+	if b := records["bob"]; b.Wins != 1 || b.Losses != 0 || b.UnitsLost != 0 || b.UnitsKilled != 1 {
+		t.Errorf("bob's record = %+v, want one win killing one unit", b)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type turnConfig struct {
+	enabled    bool
+	length     time.Duration
+	orderLimit int
+}
+
+// turnClock runs the turns of one game: it opens a turn, collects orders
+// until the deadline, then publishes them all together.
+type turnClock struct {
+	gameID string
+	roster []string
+	cfg    turnConfig
+	ch     *amqp.Channel
+	world  *world.World
+	turn   int
+	open   bool
+	orders []gamelogic.ArmyMove
+	counts map[string]int
+	done   chan struct{}
+	mu     *sync.Mutex
+}
+
+type turnClocks struct {
+	cfg    turnConfig
+	ch     *amqp.Channel
+	world  *world.World
+	clocks map[string]*turnClock
+	mu     *sync.Mutex
+}
+
+func newTurnClocks(cfg turnConfig, ch *amqp.Channel, w *world.World) *turnClocks {
+	return &turnClocks{
+		cfg:    cfg,
+		ch:     ch,
+		world:  w,
+		clocks: map[string]*turnClock{},
+		mu:     &sync.Mutex{},
+	}
+}
+
+func (tc *turnClocks) start(game routing.GameSummary) {
+	if !tc.cfg.enabled {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if _, ok := tc.clocks[game.GameID]; ok {
+		return
+	}
+	c := &turnClock{
+		gameID: game.GameID,
+		roster: game.Players,
+		cfg:    tc.cfg,
+		ch:     tc.ch,
+		world:  tc.world,
+		counts: map[string]int{},
+		done:   make(chan struct{}),
+		mu:     &sync.Mutex{},
+	}
+	tc.clocks[game.GameID] = c
+	go c.run()
+}
+
+func (tc *turnClocks) stop(gameID string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	c, ok := tc.clocks[gameID]
+	if !ok {
+		return
+	}
+	close(c.done)
+	delete(tc.clocks, gameID)
+}
+
+func (tc *turnClocks) submit(mv gamelogic.ArmyMove) error {
+	tc.mu.Lock()
+	c, ok := tc.clocks[mv.GameID]
+	tc.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("game %s is not turn-based", mv.GameID)
+	}
+	return c.submit(mv)
+}
+
+func (c *turnClock) submit(mv gamelogic.ArmyMove) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	username := mv.Player.Username
+	if !c.open {
+		return fmt.Errorf("order from %s arrived between turns", username)
+	}
+	if c.counts[username] >= c.cfg.orderLimit {
+		return fmt.Errorf("%s already gave %d order(s) in turn %d", username, c.cfg.orderLimit, c.turn)
+	}
+	c.counts[username]++
+	c.orders = append(c.orders, mv)
+	return nil
+}
+
+func (c *turnClock) run() {
+	for {
+		c.mu.Lock()
+		c.turn++
+		c.open = true
+		c.orders = nil
+		c.counts = map[string]int{}
+		turn := c.turn
+		c.mu.Unlock()
+
+		deadline := time.Now().Add(c.cfg.length)
+		if err := c.publish(gamelogic.TurnTick{
+			GameID:     c.gameID,
+			Turn:       turn,
+			Phase:      gamelogic.TurnPhaseStart,
+			Deadline:   deadline,
+			OrderLimit: c.cfg.orderLimit,
+		}); err != nil {
+			log.Printf("could not start turn %d of game %s: %v", turn, c.gameID, err)
+		}
+
+		select {
+		case <-time.After(time.Until(deadline)):
+		case <-c.done:
+			return
+		}
+
+		c.mu.Lock()
+		c.open = false
+		orders := c.orders
+		c.mu.Unlock()
+
+		orders = c.resolve(orders)
+		// every player gets the turn's orders as far as they can see them
+		for _, username := range c.roster {
+			viewer := gamelogic.Player{Username: username}
+			if a, ok := c.world.Army(username); ok {
+				viewer = a.Player
+			}
+			visible := []gamelogic.ArmyMove{}
+			for _, mv := range orders {
+				if seen, ok := mv.FilterFor(viewer); ok {
+					visible = append(visible, seen)
+				}
+			}
+			err := pubsub.PublishJSON(c.ch, routing.ExchangePerilTopic, routing.TurnsPlayerKey(c.gameID, username), gamelogic.TurnTick{
+				GameID: c.gameID,
+				Turn:   turn,
+				Phase:  gamelogic.TurnPhaseEnd,
+				Moves:  visible,
+			})
+			if err != nil {
+				log.Printf("could not resolve turn %d of game %s for %s: %v", turn, c.gameID, username, err)
+			}
+		}
+	}
+}
+
+// resolve moves every ordered unit in the world and gives each order its
+// player's army as it stands at the end of the turn. An order only carries
+// the units it moves: the rest of its snapshot was taken before the
+// player's other orders were resolved.
+func (c *turnClock) resolve(orders []gamelogic.ArmyMove) []gamelogic.ArmyMove {
+	for _, mv := range orders {
+		c.world.Move(c.gameID, mv.Player.Username, mv.Units)
+	}
+	resolved := make([]gamelogic.ArmyMove, 0, len(orders))
+	for _, mv := range orders {
+		if a, ok := c.world.Army(mv.Player.Username); ok {
+			mv.Player = a.Player
+		}
+		resolved = append(resolved, mv)
+	}
+	return resolved
+}
+
+func (c *turnClock) publish(tick gamelogic.TurnTick) error {
+	return pubsub.PublishJSON(c.ch, routing.ExchangePerilTopic, routing.TurnsKey(c.gameID), tick)
+}
+
+// handlerOrders answers every order so a refused one doesn't count against
+// the player's turn.
+func handlerOrders(clocks *turnClocks, registry *session.Registry) func(gamelogic.OrderRequest) gamelogic.OrderResponse {
+	return func(req gamelogic.OrderRequest) gamelogic.OrderResponse {
+		if !registry.Validate(req.Username, req.Token) {
+			return gamelogic.OrderResponse{Error: "register first"}
+		}
+		mv := req.Move
+		if mv.Player.Username != req.Username {
+			log.Printf("dropped an order from %s for %s's army", req.Username, mv.Player.Username)
+			return gamelogic.OrderResponse{Error: "you can only order your own units"}
+		}
+		if fromSpectator(registry, req.Username, "an order") {
+			return gamelogic.OrderResponse{Error: "spectators can not give orders"}
+		}
+		if err := clocks.submit(mv); err != nil {
+			log.Printf("rejected order: %v", err)
+			return gamelogic.OrderResponse{Error: err.Error()}
+		}
+		return gamelogic.OrderResponse{}
+	}
+}
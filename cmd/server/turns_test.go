@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/session"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+)
+
+func unit(id int, loc gamelogic.Location) gamelogic.Unit {
+	return gamelogic.Unit{ID: id, Rank: gamelogic.RankInfantry, Location: loc}
+}
+
+// openClock is a turn clock in the middle of an open turn, without the
+// goroutine that would publish its ticks.
+func openClock(w *world.World) (*turnClocks, *turnClock) {
+	cfg := turnConfig{enabled: true, length: time.Minute, orderLimit: 2}
+	clocks := newTurnClocks(cfg, nil, w)
+	c := &turnClock{
+		gameID: "g1",
+		roster: []string{"alice", "bob"},
+		cfg:    cfg,
+		world:  w,
+		turn:   1,
+		open:   true,
+		counts: map[string]int{},
+		done:   make(chan struct{}),
+		mu:     &sync.Mutex{},
+	}
+	clocks.clocks["g1"] = c
+	return clocks, c
+}
+
+// Each order's snapshot was taken before the player's other orders of the
+// turn were resolved, so the second must not put the first's units back.
+func TestTwoOrdersInOneTurn(t *testing.T) {
+	w := world.New()
+	w.Update("g1", gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+		1: unit(1, "europe"),
+		2: unit(2, "europe"),
+	}})
+	_, c := openClock(w)
+
+	first := gamelogic.ArmyMove{
+		GameID:     "g1",
+		ToLocation: "asia",
+		Units:      []gamelogic.Unit{unit(1, "asia")},
+		Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+			1: unit(1, "asia"),
+			2: unit(2, "europe"),
+		}},
+	}
+	second := gamelogic.ArmyMove{
+		GameID:     "g1",
+		ToLocation: "africa",
+		Units:      []gamelogic.Unit{unit(2, "africa")},
+		Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+			1: unit(1, "europe"),
+			2: unit(2, "africa"),
+		}},
+	}
+	for _, mv := range []gamelogic.ArmyMove{first, second} {
+		if err := c.submit(mv); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+
+	resolved := c.resolve(c.orders)
+	want := map[int]gamelogic.Unit{1: unit(1, "asia"), 2: unit(2, "africa")}
+	a, _ := w.Army("alice")
+	if !reflect.DeepEqual(a.Player.Units, want) {
+		t.Errorf("army after the turn = %v, want %v", a.Player.Units, want)
+	}
+	if len(resolved) != 2 {
+		t.Fatalf("resolved %d orders, want 2", len(resolved))
+	}
+	for i, mv := range resolved {
+		if !reflect.DeepEqual(mv.Player.Units, want) {
+			t.Errorf("order %d carries %v, want the army after the turn", i, mv.Player.Units)
+		}
+	}
+}
+
+func TestHandlerOrdersAuthenticates(t *testing.T) {
+	registry := session.NewRegistry(time.Minute)
+	alice, err := registry.Register("alice", "conn-alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register("bob", "conn-bob", false); err != nil {
+		t.Fatal(err)
+	}
+	clocks, c := openClock(world.New())
+	handle := handlerOrders(clocks, registry)
+	move := func(username string) gamelogic.ArmyMove {
+		return gamelogic.ArmyMove{
+			GameID: "g1",
+			Units:  []gamelogic.Unit{unit(1, "asia")},
+			Player: gamelogic.Player{Username: username},
+		}
+	}
+
+	tests := []struct {
+		name string
+		req  gamelogic.OrderRequest
+		ok   bool
+	}{
+		{"no token", gamelogic.OrderRequest{Username: "alice", Move: move("alice")}, false},
+		{"someone else's token", gamelogic.OrderRequest{Username: "bob", Token: alice.Token, Move: move("bob")}, false},
+		{"someone else's army", gamelogic.OrderRequest{Username: "alice", Token: alice.Token, Move: move("bob")}, false},
+		{"own army", gamelogic.OrderRequest{Username: "alice", Token: alice.Token, Move: move("alice")}, true},
+	}
+	for _, tt := range tests {
+		resp := handle(tt.req)
+		if ok := resp.Error == ""; ok != tt.ok {
+			t.Errorf("%s: order accepted = %v (%q), want %v", tt.name, ok, resp.Error, tt.ok)
+		}
+	}
+	if len(c.orders) != 1 || c.counts["bob"] != 0 {
+		t.Errorf("clock took %d orders, %d of them bob's, want only alice's", len(c.orders), c.counts["bob"])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lobby"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/victory"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const victoryCheckInterval = 5 * time.Second
+
+// referee runs everything a started game needs: its turn clock, its economy
+// and a judge that ends the game once someone has won.
+type referee struct {
+	cond   victory.Conditions
+	clocks *turnClocks
+	econ   *economy
+	lobby  *lobby.Lobby
+	world  *world.World
+	ch     *amqp.Channel
+	judges map[string]*victory.Judge
+	done   map[string]chan struct{}
+	mu     *sync.Mutex
+}
+
+func newReferee(cond victory.Conditions, clocks *turnClocks, econ *economy, l *lobby.Lobby, w *world.World, ch *amqp.Channel) *referee {
+	return &referee{
+		cond:   cond,
+		clocks: clocks,
+		econ:   econ,
+		lobby:  l,
+		world:  w,
+		ch:     ch,
+		judges: map[string]*victory.Judge{},
+		done:   map[string]chan struct{}{},
+		mu:     &sync.Mutex{},
+	}
+}
+
+func (r *referee) start(game routing.GameSummary) {
+	r.clocks.start(game)
+	r.econ.start(game)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.judges[game.GameID]; ok {
+		return
+	}
+	j := victory.NewJudge(r.cond, game.Players)
+	done := make(chan struct{})
+	r.judges[game.GameID] = j
+	r.done[game.GameID] = done
+	go r.watch(game.GameID, j, done)
+}
+
+func (r *referee) watch(gameID string, j *victory.Judge, done chan struct{}) {
+	ticker := time.NewTicker(victoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		r.mu.Lock()
+		res, won := j.Evaluate(time.Now(), r.players(gameID), r.world.Alliances(gameID))
+		r.mu.Unlock()
+		if won {
+			r.finish(gameID, res)
+			return
+		}
+	}
+}
+
+func (r *referee) finish(gameID string, res victory.Result) {
+	log.Printf("game %s is over: %s", gameID, res.Reason)
+	r.clocks.stop(gameID)
+	r.econ.stop(gameID)
+	r.lobby.Finish(gameID)
+	err := pubsub.PublishJSON(r.ch, routing.ExchangePerilTopic, routing.GameOverKey(gameID), gamelogic.GameOver{
+		GameID:    gameID,
+		Winner:    res.Winner,
+		Allies:    res.Allies,
+		Condition: res.Condition,
+		Reason:    res.Reason,
+		At:        time.Now(),
+		Territory: res.Territory,
+	})
+	if err != nil {
+		log.Printf("could not announce the end of game %s: %v", gameID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.judges, gameID)
+	delete(r.done, gameID)
+}
+
+func (r *referee) players(gameID string) []gamelogic.Player {
+	players := []gamelogic.Player{}
+	for _, a := range r.world.Armies(gameID) {
+		players = append(players, a.Player)
+	}
+	return players
+}
+
+func (r *referee) territory(gameID string) error {
+	r.mu.Lock()
+	j, ok := r.judges[gameID]
+	var scores map[string]int
+	if ok {
+		scores = j.Scores()
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("game %s is not running", gameID)
+	}
+
+	owners := gamelogic.Territory(r.players(gameID))
+	for _, loc := range gamelogic.Locations() {
+		owner, held := owners[loc]
+		if !held {
+			owner = "nobody"
+		}
+		fmt.Printf("* %s: %s\n", loc, owner)
+	}
+	usernames := []string{}
+	for username := range scores {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	for _, username := range usernames {
+		fmt.Printf("%s has %d point(s)\n", username, scores[username])
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// View is what a strategy knows when it picks its next command.
+type View struct {
+	Player    gamelogic.Player
+	Resources int
+	Ruleset   gamelogic.Ruleset
+	// Enemies counts the last known enemy units in each location.
+	Enemies map[gamelogic.Location]int
+}
+
+func NewView(gs *gamelogic.GameState) View {
+	return View{
+		Player:    gs.GetPlayerSnap(),
+		Resources: gs.GetResources(),
+		Ruleset:   gs.Ruleset(),
+		Enemies:   gs.KnownEnemies(),
+	}
+}
+
+// Strategy picks a bot's commands. Next returns the words of a client command
+// such as spawn or move, or nil to do nothing this round.
+type Strategy interface {
+	Next(v View) []string
+}
+
+var Strategies = []string{"random", "aggressive", "defensive"}
+
+func NewStrategy(name string, rng *rand.Rand) (Strategy, error) {
+	switch name {
+	case "random":
+		return &randomStrategy{rng: rng}, nil
+	case "aggressive":
+		return &aggressiveStrategy{rng: rng}, nil
+	case "defensive":
+		return &defensiveStrategy{rng: rng}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q, use one of %v", name, Strategies)
+	}
+}
+
+// randomStrategy spawns and moves at random.
+type randomStrategy struct {
+	rng *rand.Rand
+}
+
+func (s *randomStrategy) Next(v View) []string {
+	locations := gamelogic.Locations()
+	if len(v.Player.Units) == 0 || s.rng.Intn(2) == 0 {
+		ranks := affordable(v)
+		if len(ranks) == 0 {
+			return nil
+		}
+		loc := locations[s.rng.Intn(len(locations))]
+		return spawn(loc, ranks[s.rng.Intn(len(ranks))])
+	}
+	units := sortedUnits(v.Player)
+	u := units[s.rng.Intn(len(units))]
+	neighbours := gamelogic.Adjacent()[u.Location]
+	return move(neighbours[s.rng.Intn(len(neighbours))], u)
+}
+
+// aggressiveStrategy buys the strongest unit it can and marches its biggest
+// stack at the weakest enemy it knows about, scouting when it knows none.
+type aggressiveStrategy struct {
+	rng *rand.Rand
+}
+
+func (s *aggressiveStrategy) Next(v View) []string {
+	stacks := stacksOf(v.Player)
+	if ranks := affordable(v); len(ranks) > 0 && (len(v.Player.Units) == 0 || s.rng.Intn(3) == 0) {
+		loc := biggestStack(stacks)
+		if loc == "" {
+			locations := gamelogic.Locations()
+			loc = locations[s.rng.Intn(len(locations))]
+		}
+		return spawn(loc, ranks[len(ranks)-1])
+	}
+	from := biggestStack(stacks)
+	if from == "" {
+		return nil
+	}
+	neighbours := gamelogic.Adjacent()[from]
+	var target gamelogic.Location
+	for _, loc := range neighbours {
+		n := v.Enemies[loc]
+		if n > 0 && (target == "" || n < v.Enemies[target]) {
+			target = loc
+		}
+	}
+	if target == "" {
+		target = neighbours[s.rng.Intn(len(neighbours))]
+	}
+	return move(target, stacks[from]...)
+}
+
+// defensiveStrategy never attacks. It saves for artillery and puts it where
+// it is most threatened, pulling lone units back into its main stack.
+type defensiveStrategy struct {
+	rng *rand.Rand
+}
+
+func (s *defensiveStrategy) Next(v View) []string {
+	stacks := stacksOf(v.Player)
+	home := biggestStack(stacks)
+	if home == "" {
+		ranks := affordable(v)
+		if len(ranks) == 0 {
+			return nil
+		}
+		locations := gamelogic.Locations()
+		return spawn(locations[s.rng.Intn(len(locations))], ranks[0])
+	}
+
+	adjacent := gamelogic.Adjacent()
+	for _, loc := range sortedLocations(stacks) {
+		if loc == home {
+			continue
+		}
+		for _, n := range adjacent[loc] {
+			if n == home && v.Enemies[home] == 0 {
+				return move(home, stacks[loc]...)
+			}
+		}
+	}
+
+	if v.Resources < v.Ruleset.Cost(gamelogic.RankArtillery) {
+		return nil
+	}
+	threatened := home
+	most := -1
+	for _, loc := range sortedLocations(stacks) {
+		threat := v.Enemies[loc]
+		for _, n := range adjacent[loc] {
+			threat += v.Enemies[n]
+		}
+		if threat > most {
+			threatened, most = loc, threat
+		}
+	}
+	return spawn(threatened, gamelogic.RankArtillery)
+}
+
+// affordable lists the ranks the player can pay for, cheapest first.
+func affordable(v View) []gamelogic.UnitRank {
+	ranks := []gamelogic.UnitRank{}
+	for rank, cost := range v.Ruleset.RankCosts {
+		if cost <= v.Resources {
+			ranks = append(ranks, rank)
+		}
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		return v.Ruleset.Cost(ranks[i]) < v.Ruleset.Cost(ranks[j])
+	})
+	return ranks
+}
+
+func stacksOf(p gamelogic.Player) map[gamelogic.Location][]gamelogic.Unit {
+	stacks := map[gamelogic.Location][]gamelogic.Unit{}
+	for _, u := range sortedUnits(p) {
+		stacks[u.Location] = append(stacks[u.Location], u)
+	}
+	return stacks
+}
+
+func biggestStack(stacks map[gamelogic.Location][]gamelogic.Unit) gamelogic.Location {
+	var best gamelogic.Location
+	for _, loc := range sortedLocations(stacks) {
+		if best == "" || len(stacks[loc]) > len(stacks[best]) {
+			best = loc
+		}
+	}
+	return best
+}
+
+func sortedLocations(stacks map[gamelogic.Location][]gamelogic.Unit) []gamelogic.Location {
+	locations := []gamelogic.Location{}
+	for loc := range stacks {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+func sortedUnits(p gamelogic.Player) []gamelogic.Unit {
+	units := []gamelogic.Unit{}
+	for _, u := range p.Units {
+		units = append(units, u)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units
+}
+
+func spawn(loc gamelogic.Location, rank gamelogic.UnitRank) []string {
+	return []string{"spawn", string(loc), string(rank)}
+}
+
+func move(to gamelogic.Location, units ...gamelogic.Unit) []string {
+	words := []string{"move", string(to)}
+	for _, u := range units {
+		words = append(words, strconv.Itoa(u.ID))
+	}
+	return words
+}
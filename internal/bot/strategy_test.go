@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func units(locs ...gamelogic.Location) gamelogic.Player {
+	p := gamelogic.Player{Username: "bot", Units: map[int]gamelogic.Unit{}}
+	for i, loc := range locs {
+		p.Units[i+1] = gamelogic.Unit{ID: i + 1, Rank: gamelogic.RankInfantry, Location: loc}
+	}
+	return p
+}
+
+// cheapArtillery makes artillery cheaper than cavalry, so a strategy that
+// reads the costs from anywhere but its view buys the wrong unit.
+func cheapArtillery() gamelogic.Ruleset {
+	r := gamelogic.DefaultRuleset()
+	r.RankCosts = map[gamelogic.UnitRank]int{
+		gamelogic.RankInfantry:  1,
+		gamelogic.RankCavalry:   20,
+		gamelogic.RankArtillery: 3,
+	}
+	return r
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		view     View
+		// want is the exact command, unless check is set
+		want  []string
+		check func(t *testing.T, words []string)
+	}{
+		{
+			name:     "random can't afford anything",
+			strategy: "random",
+			view:     View{Player: units(), Ruleset: gamelogic.DefaultRuleset()},
+		},
+		{
+			name:     "random spawns what it can afford",
+			strategy: "random",
+			view:     View{Player: units(), Resources: 1, Ruleset: gamelogic.DefaultRuleset()},
+			check:    spawns(gamelogic.RankInfantry),
+		},
+		{
+			name:     "random only moves to a neighbour",
+			strategy: "random",
+			view:     View{Player: units("europe"), Ruleset: gamelogic.DefaultRuleset()},
+			check: func(t *testing.T, words []string) {
+				if words == nil {
+					return
+				}
+				if words[0] != "move" || !adjacent("europe", gamelogic.Location(words[1])) {
+					t.Errorf("command %v is not a move to a neighbour of europe", words)
+				}
+			},
+		},
+		{
+			name:     "aggressive buys the strongest unit it can",
+			strategy: "aggressive",
+			view:     View{Player: units(), Resources: 10, Ruleset: gamelogic.DefaultRuleset()},
+			check:    spawns(gamelogic.RankArtillery),
+		},
+		{
+			name:     "aggressive reads costs from the view",
+			strategy: "aggressive",
+			view:     View{Player: units(), Resources: 10, Ruleset: cheapArtillery()},
+			check:    spawns(gamelogic.RankArtillery),
+		},
+		{
+			name:     "aggressive attacks the weakest neighbour with its biggest stack",
+			strategy: "aggressive",
+			view: View{
+				Player:  units("europe", "europe", "australia"),
+				Ruleset: gamelogic.DefaultRuleset(),
+				Enemies: map[gamelogic.Location]int{"africa": 3, "americas": 1, "antarctica": 1},
+			},
+			want: []string{"move", "americas", "1", "2"},
+		},
+		{
+			name:     "aggressive does nothing without units or resources",
+			strategy: "aggressive",
+			view:     View{Player: units(), Ruleset: gamelogic.DefaultRuleset()},
+		},
+		{
+			name:     "defensive starts with the cheapest unit",
+			strategy: "defensive",
+			view:     View{Player: units(), Resources: 10, Ruleset: gamelogic.DefaultRuleset()},
+			check:    spawns(gamelogic.RankInfantry),
+		},
+		{
+			name:     "defensive pulls a lone unit home",
+			strategy: "defensive",
+			view:     View{Player: units("europe", "europe", "asia"), Ruleset: gamelogic.DefaultRuleset()},
+			want:     []string{"move", "europe", "3"},
+		},
+		{
+			name:     "defensive leaves a lone unit out while home is threatened",
+			strategy: "defensive",
+			view: View{
+				Player:  units("europe", "europe", "asia"),
+				Ruleset: gamelogic.DefaultRuleset(),
+				Enemies: map[gamelogic.Location]int{"europe": 1},
+			},
+		},
+		{
+			name:     "defensive saves for artillery",
+			strategy: "defensive",
+			view:     View{Player: units("europe"), Resources: 8, Ruleset: gamelogic.DefaultRuleset()},
+		},
+		{
+			name:     "defensive puts artillery where it is most threatened",
+			strategy: "defensive",
+			view: View{
+				Player:    units("europe", "europe", "australia"),
+				Resources: 3,
+				Ruleset:   cheapArtillery(),
+				Enemies:   map[gamelogic.Location]int{"asia": 2, "antarctica": 1},
+			},
+			want: []string{"spawn", "australia", "artillery"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every seed must give a sensible command, not just a lucky one
+			for seed := int64(0); seed < 20; seed++ {
+				s, err := NewStrategy(tt.strategy, rand.New(rand.NewSource(seed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := s.Next(tt.view)
+				if tt.check != nil {
+					tt.check(t, got)
+					continue
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("seed %d: Next = %v, want %v", seed, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewStrategyRejectsUnknown(t *testing.T) {
+	if _, err := NewStrategy("cautious", rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("NewStrategy accepted an unknown strategy")
+	}
+}
+
+// spawns checks for a spawn of rank anywhere.
+func spawns(rank gamelogic.UnitRank) func(t *testing.T, words []string) {
+	return func(t *testing.T, words []string) {
+		t.Helper()
+		if len(words) != 3 || words[0] != "spawn" || words[2] != string(rank) {
+			t.Fatalf("command %v is not a spawn of %s", words, rank)
+		}
+	}
+}
+
+func adjacent(a, b gamelogic.Location) bool {
+	for _, n := range gamelogic.Adjacent()[a] {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
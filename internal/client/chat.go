@@ -0,0 +1,34 @@
+package client
+
+import (
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// subscribeChat binds one queue to every chat channel the player can hear
+// outside a game. JoinGame adds the game's channel.
+func subscribeChat(c *Client) error {
+	username := c.sess.Username
+	queue := routing.ChatQueue(username)
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, queue, routing.ChatDirectKey(username), pubsub.QueueTransient, observed(c, EventChat, handlerChat(c.gs)))
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{
+		routing.ChatGlobalKey(),
+		routing.ChatAllianceKey(username),
+	} {
+		if err := pubsub.AddBinding(c.conn, routing.ExchangePerilTopic, queue, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handlerChat(gs *gamelogic.GameState) func(routing.ChatMessage) pubsub.Acktype {
+	return func(msg routing.ChatMessage) pubsub.Acktype {
+		gs.HandleChat(msg)
+		return pubsub.Ack
+	}
+}
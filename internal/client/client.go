@@ -0,0 +1,264 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Client connects a player's GameState to the broker: it keeps the session
+// alive, follows the game's queues and publishes what commands produce.
+type Client struct {
+	conn *amqp.Connection
+	ch   *amqp.Channel
+	gs   *gamelogic.GameState
+	sess Session
+	done chan struct{}
+	// gameID is set by JoinGame, which may run on another goroutine than
+	// the commands.
+	gameID string
+	mu     *sync.Mutex
+	// commands are the in-game commands Execute runs.
+	commands *gamelogic.Registry
+	quitting bool
+	// watched holds every army of a spectated game.
+	watched *world.World
+	// Observer, if set, is told about every message that was handled and
+	// acked. It runs on the consumer's goroutine.
+	Observer func(kind string, msg any)
+	// OnKick, if set, runs after the server kicked or banned the player.
+	// It runs on the consumer's goroutine.
+	OnKick func(routing.AdminNotice)
+	// kicked is closed once the server removed the player.
+	kicked   chan struct{}
+	kickOnce *sync.Once
+	input    chan []string
+	reading  bool
+}
+
+// ErrKicked is returned by GetInput once the server kicked or banned the
+// player.
+var ErrKicked = errors.New("removed by the server")
+
+func New(conn *amqp.Connection, ch *amqp.Channel, gs *gamelogic.GameState, sess Session) *Client {
+	c := &Client{
+		conn:     conn,
+		ch:       ch,
+		gs:       gs,
+		sess:     sess,
+		done:     make(chan struct{}),
+		kicked:   make(chan struct{}),
+		kickOnce: &sync.Once{},
+		input:    make(chan []string),
+		mu:       &sync.Mutex{},
+	}
+	c.commands = c.newCommands()
+	return c
+}
+
+// Kicked is closed once the server kicked or banned the player.
+func (c *Client) Kicked() <-chan struct{} {
+	return c.kicked
+}
+
+// GetInput is gamelogic.GetInput that gives up with ErrKicked as soon as the
+// server removes the player, even while it waits for a line. Only one
+// goroutine may call it.
+func (c *Client) GetInput() ([]string, error) {
+	select {
+	case <-c.kicked:
+		return nil, ErrKicked
+	default:
+	}
+	if !c.reading {
+		c.reading = true
+		go func() {
+			c.input <- gamelogic.GetInput()
+		}()
+	}
+	select {
+	case words := <-c.input:
+		c.reading = false
+		return words, nil
+	case <-c.kicked:
+		return nil, ErrKicked
+	}
+}
+
+func (c *Client) GameState() *gamelogic.GameState {
+	return c.gs
+}
+
+func (c *Client) GameID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gameID
+}
+
+// Start sends heartbeats and subscribes to the queues a player follows
+// whether or not they are in a game.
+func (c *Client) Start() error {
+	go sendHeartbeats(c.ch, c.sess, c.gs, c.done)
+	username := c.sess.Username
+	presenceQueue := routing.PresenceEventKey(username)
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, presenceQueue, routing.PresenceEventBinding(), pubsub.QueueTransient, observed(c, EventPresence, handlerPresence(c.gs)))
+	if err != nil {
+		return err
+	}
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, routing.AnnouncementsQueue(username), routing.AnnouncementsKey(), pubsub.QueueTransient, observed(c, EventAnnouncement, handlerAnnouncement(c.gs)))
+	if err != nil {
+		return err
+	}
+	if !c.sess.Spectator {
+		// whispers can reach a player before their game's queues exist
+		if err := subscribeChat(c); err != nil {
+			return err
+		}
+	}
+	adminKey := routing.AdminKey(username)
+	return pubsub.SubscribeJSONFrom(c.conn, routing.ExchangePerilTopic, adminKey, adminKey, pubsub.QueueTransient, c.handlerAdminNotice())
+}
+
+// JoinGame subscribes to the queues of the game that start opened.
+func (c *Client) JoinGame(start routing.PlayingState) error {
+	gameID := start.GameID
+	username := c.sess.Username
+	c.mu.Lock()
+	c.gameID = gameID
+	c.mu.Unlock()
+	gs := c.gs
+
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, routing.ArmyMovesQueue(gameID, username), routing.VisibleMovesKey(gameID, username), pubsub.QueueTransient, observed(c, EventMove, handlerMove(gs, c.ch, gameID)))
+	if err != nil {
+		return err
+	}
+
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, routing.WarRecognitionsQueue(gameID), routing.WarRecognitionsBinding(gameID), pubsub.QueueDurable, observed(c, EventWar, handlerWar(gs, c.ch, gameID, c.sess)))
+	if err != nil {
+		return err
+	}
+
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, routing.IncomeQueue(gameID, username), routing.IncomeKey(gameID), pubsub.QueueTransient, observed(c, EventIncome, handlerIncome(gs, c.ch)))
+	if err != nil {
+		return err
+	}
+
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, routing.GameOverQueue(gameID, username), routing.GameOverKey(gameID), pubsub.QueueTransient, observed(c, EventGameOver, handlerGameOver(gs)))
+	if err != nil {
+		return err
+	}
+
+	diplomacyKey := routing.DiplomacyKey(gameID, username)
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, diplomacyKey, diplomacyKey, pubsub.QueueTransient, observed(c, EventDiplomacy, handlerDiplomacy(gs)))
+	if err != nil {
+		return err
+	}
+
+	if err := pubsub.AddBinding(c.conn, routing.ExchangePerilTopic, routing.ChatQueue(username), routing.ChatGameKey(gameID)); err != nil {
+		return err
+	}
+
+	// resumed units must be visible to the server before anyone moves
+	if err := publishArmyReport(c.ch, gs, gameID); err != nil {
+		fmt.Fprintf(c.gs.Output(), "publish error: %v\n", err)
+	}
+	return nil
+}
+
+// Execute runs one in-game command and publishes its result. It reports
+// whether the player quit.
+func (c *Client) Execute(words []string) bool {
+	if err := c.Run(words); err != nil {
+		fmt.Fprintln(c.gs.Output(), err)
+	}
+	return c.quitting
+}
+
+// Run is Execute for callers that handle the error themselves.
+func (c *Client) Run(words []string) error {
+	return c.commands.Run(words)
+}
+
+// Quit autosaves and tells the server the player left.
+func (c *Client) Quit() {
+	if path, err := c.gs.Autosave(); err != nil {
+		fmt.Fprintf(c.gs.Output(), "autosave error: %v\n", err)
+	} else {
+		fmt.Fprintf(c.gs.Output(), "autosaved game to %s\n", path)
+	}
+	c.Leave()
+	gamelogic.PrintQuit()
+}
+
+// Leave stops the heartbeats and sends the last one.
+func (c *Client) Leave() {
+	select {
+	case <-c.done:
+		return
+	default:
+		close(c.done)
+	}
+	if err := publishHeartbeat(c.ch, c.sess, c.gs, true); err != nil {
+		fmt.Fprintf(c.gs.Output(), "heartbeat error: %v\n", err)
+	}
+}
+
+const (
+	EventPresence     = "presence"
+	EventAnnouncement = "announcement"
+	EventPause        = "pause"
+	EventMove         = "move"
+	EventWar          = "war"
+	EventIncome       = "income"
+	EventGameOver     = "gameover"
+	EventDiplomacy    = "diplomacy"
+	EventTurn         = "turn"
+	EventChat         = "chat"
+	EventLobby        = "lobby"
+	EventArmyReport   = "army_report"
+	EventLog          = "log"
+)
+
+func observed[T any](c *Client, kind string, handler func(T) pubsub.Acktype) func(T) pubsub.Acktype {
+	return func(v T) pubsub.Acktype {
+		ack := handler(v)
+		if ack == pubsub.Ack && c.Observer != nil {
+			c.Observer(kind, v)
+		}
+		return ack
+	}
+}
+
+func handlerAnnouncement(gs *gamelogic.GameState) func(routing.Announcement) pubsub.Acktype {
+	return func(a routing.Announcement) pubsub.Acktype {
+		gs.HandleAnnouncement(a)
+		return pubsub.Ack
+	}
+}
+
+// handlerAdminNotice only believes notices the broker says a server sent.
+func (c *Client) handlerAdminNotice() func(routing.AdminNotice, string) pubsub.Acktype {
+	return func(n routing.AdminNotice, sender string) pubsub.Acktype {
+		if !routing.IsServerUser(sender) {
+			fmt.Fprintf(c.gs.Output(), "ignored an admin notice from %q\n", sender)
+			return pubsub.NackDiscard
+		}
+		c.gs.HandleAdminNotice(n)
+		if _, err := c.gs.Autosave(); err != nil {
+			fmt.Fprintf(c.gs.Output(), "autosave error: %v\n", err)
+		}
+		c.kickOnce.Do(func() {
+			close(c.kicked)
+		})
+		if c.OnKick != nil {
+			c.OnKick(n)
+		}
+		return pubsub.Ack
+	}
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lineedit"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func (c *Client) newCommands() *gamelogic.Registry {
+	r := gamelogic.NewRegistry("Possible commands:")
+	r.Register(gamelogic.Command{
+		Name:    "move",
+		Aliases: []string{"mv"},
+		Args: []gamelogic.Arg{
+			{Name: "location", Type: gamelogic.ArgLocation},
+			{Name: "unitID", Type: gamelogic.ArgUnitID, Variadic: true},
+		},
+		Example: "move asia 1",
+		Run:     c.move,
+	})
+	r.Register(gamelogic.Command{
+		Name: "spawn",
+		Args: []gamelogic.Arg{
+			{Name: "location", Type: gamelogic.ArgLocation},
+			{Name: "rank", Type: gamelogic.ArgRank},
+		},
+		Help:    c.gs.Ruleset().DescribeCosts(),
+		Example: "spawn europe infantry",
+		Run:     c.spawn,
+	})
+	r.Register(gamelogic.Command{
+		Name:    "status",
+		Aliases: []string{"st"},
+		Run: func(gamelogic.Args) error {
+			c.gs.CommandStatus()
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "alliance",
+		Args: []gamelogic.Arg{
+			{Name: "propose|accept|break"},
+			{Name: "username", Type: gamelogic.ArgUsername},
+		},
+		Example: "alliance propose washington",
+		Run:     c.alliance,
+	})
+	r.Register(gamelogic.Command{
+		Name:    "say",
+		Args:    []gamelogic.Arg{{Name: "message", Variadic: true}},
+		Usage:   "[global|alliance] <message>",
+		Example: "say good luck everyone",
+		Run: func(args gamelogic.Args) error {
+			msg, err := c.gs.CommandSay(args.Strings("message"))
+			if err != nil {
+				return err
+			}
+			return c.sendChat(msg)
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "whisper",
+		Aliases: []string{"w"},
+		Args: []gamelogic.Arg{
+			{Name: "username", Type: gamelogic.ArgUsername},
+			{Name: "message", Variadic: true},
+		},
+		Run: func(args gamelogic.Args) error {
+			msg, err := c.gs.CommandWhisper(args.String("username"), args.String("message"))
+			if err != nil {
+				return err
+			}
+			return c.sendChat(msg)
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "save",
+		Args:    []gamelogic.Arg{{Name: "name"}},
+		Example: "save beforewar",
+		Run: func(args gamelogic.Args) error {
+			return c.gs.CommandSave(args.String("name"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "load",
+		Args:    []gamelogic.Arg{{Name: "name"}},
+		Example: "load beforewar",
+		Run: func(args gamelogic.Args) error {
+			return c.gs.CommandLoad(args.String("name"))
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "leaderboard",
+		Aliases: []string{"top"},
+		Args:    []gamelogic.Arg{{Name: "n", Type: gamelogic.ArgInt, Optional: true}},
+		Run:     c.leaderboard,
+	})
+	r.Register(gamelogic.Command{
+		Name:    "spam",
+		Args:    []gamelogic.Arg{{Name: "n", Type: gamelogic.ArgInt}},
+		Example: "spam 5",
+		Run: func(gamelogic.Args) error {
+			fmt.Fprintln(c.gs.Output(), "Spamming not allowed yet!")
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "quit",
+		Aliases: []string{"exit"},
+		Run: func(gamelogic.Args) error {
+			c.Quit()
+			c.quitting = true
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Run: func(gamelogic.Args) error {
+			r.FprintHelp(c.gs.Output())
+			return nil
+		},
+	})
+	return r
+}
+
+func (c *Client) spawn(args gamelogic.Args) error {
+	fmt.Fprintln(c.gs.Output(), "player is attempting to spawn a new unit")
+	loc, rank := args.Location("location"), args.Rank("rank")
+	if err := c.gs.CheckSpawn(loc, rank); err != nil {
+		return err
+	}
+	gameID := c.GameID()
+	// the server keeps the balance, so it charges before the unit exists
+	resp, err := pubsub.CallJSON[gamelogic.SpawnRequest, gamelogic.SpawnResponse](
+		c.conn,
+		routing.ExchangePerilTopic,
+		routing.SpawnKey(gameID, c.sess.Username),
+		gamelogic.SpawnRequest{
+			GameID:   gameID,
+			Username: c.sess.Username,
+			Token:    c.sess.Token,
+			Location: loc,
+			Rank:     rank,
+		},
+		registerTimeout,
+	)
+	if errors.Is(err, pubsub.ErrTimeout) {
+		return errors.New("the server did not answer, is it running?")
+	}
+	if err != nil {
+		return fmt.Errorf("could not pay for the unit: %v", err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	c.gs.CommandSpawn(loc, rank, resp.Resources)
+	if err := publishArmyReport(c.ch, c.gs, gameID); err != nil {
+		fmt.Fprintf(c.gs.Output(), "publish error: %v\n", err)
+	}
+	return nil
+}
+
+func (c *Client) move(args gamelogic.Args) error {
+	mv, err := c.gs.CommandMove(args.Location("location"), args.UnitIDs("unitID"))
+	if err != nil {
+		return err
+	}
+	if c.gs.InTurnMode() {
+		return c.order(mv)
+	}
+	rk := routing.ArmyMovesKey(c.GameID(), c.sess.Username)
+	if err := pubsub.PublishJSON(c.ch, routing.ExchangePerilServer, rk, mv); err != nil {
+		fmt.Fprintf(c.gs.Output(), "publish error: %v\n", err)
+	} else {
+		fmt.Fprintln(c.gs.Output(), "published move")
+	}
+	fmt.Fprintf(c.gs.Output(), "move successful: %d unit(s) to %s\n", len(mv.Units), mv.ToLocation)
+	return nil
+}
+
+// order hands a move to the server's turn clock, which answers whether it
+// was accepted in time.
+func (c *Client) order(mv gamelogic.ArmyMove) error {
+	resp, err := pubsub.CallJSON[gamelogic.OrderRequest, gamelogic.OrderResponse](
+		c.conn,
+		routing.ExchangePerilServer,
+		routing.OrdersKey(c.GameID(), c.sess.Username),
+		gamelogic.OrderRequest{Username: c.sess.Username, Token: c.sess.Token, Move: mv},
+		registerTimeout,
+	)
+	if errors.Is(err, pubsub.ErrTimeout) {
+		err = errors.New("the server did not answer, is it running?")
+	}
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err != nil {
+		c.gs.ReleaseOrder()
+		return fmt.Errorf("order rejected: %v", err)
+	}
+	fmt.Fprintf(c.gs.Output(), "Ordered %v units to %s at the end of the turn\n", len(mv.Units), mv.ToLocation)
+	return nil
+}
+
+func (c *Client) alliance(args gamelogic.Args) error {
+	d, err := c.gs.CommandAlliance(args.String("propose|accept|break"), args.String("username"))
+	if err != nil {
+		return err
+	}
+	if err := pubsub.PublishJSON(c.ch, routing.ExchangePerilTopic, routing.DiplomacyKey(c.GameID(), d.To), d); err != nil {
+		fmt.Fprintf(c.gs.Output(), "publish error: %v\n", err)
+	}
+	return nil
+}
+
+func (c *Client) leaderboard(args gamelogic.Args) error {
+	players, err := c.Leaderboard(args.Int("n"))
+	if err != nil {
+		return err
+	}
+	gamelogic.PrintLeaderboard(c.gs.Output(), players)
+	return nil
+}
+
+// Leaderboard asks the server for the top n players, or all of them when n
+// is zero.
+func (c *Client) Leaderboard(n int) ([]gamelogic.PlayerStats, error) {
+	resp, err := pubsub.CallJSON[gamelogic.LeaderboardRequest, gamelogic.LeaderboardResponse](
+		c.conn,
+		routing.ExchangePerilRPC,
+		routing.LeaderboardKey,
+		gamelogic.LeaderboardRequest{Limit: n},
+		registerTimeout,
+	)
+	if errors.Is(err, pubsub.ErrTimeout) {
+		return nil, errors.New("the server did not answer, is it running?")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the leaderboard: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Players, nil
+}
+
+func (c *Client) sendChat(msg routing.ChatMessage) error {
+	msg.Token = c.sess.Token
+	if err := pubsub.PublishJSON(c.ch, routing.ExchangePerilTopic, routing.ChatRequestKey(c.sess.Username), msg); err != nil {
+		fmt.Fprintf(c.gs.Output(), "publish error: %v\n", err)
+	}
+	return nil
+}
+
+// PrintHelp lists the in-game commands.
+func (c *Client) PrintHelp() {
+	c.commands.FprintHelp(c.gs.Output())
+}
+
+// Commands lists every in-game command name and alias.
+func (c *Client) Commands() []string {
+	return c.commands.Names()
+}
+
+// Completer completes in-game commands, including the IDs of our own units
+// and the names of players we have met.
+func (c *Client) Completer() lineedit.Completer {
+	return c.commands.Completer(func(t gamelogic.ArgType) []string {
+		switch t {
+		case gamelogic.ArgUnitID:
+			ids := []int{}
+			for id := range c.gs.GetPlayerSnap().Units {
+				ids = append(ids, id)
+			}
+			sort.Ints(ids)
+			values := []string{}
+			for _, id := range ids {
+				values = append(values, strconv.Itoa(id))
+			}
+			return values
+		case gamelogic.ArgUsername:
+			return c.gs.KnownPlayers()
+		}
+		return gamelogic.CompletionValues(t)
+	})
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func handlerWar(gs *gamelogic.GameState, ch *amqp.Channel, gameID string, sess Session) func(gamelogic.RecognitionOfWar) pubsub.Acktype {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.Acktype {
+		warOutcome, winner, loser := gs.HandleWar(rw)
+		logMessage := ""
+		ackType := pubsub.NackDiscard
+		var result gamelogic.WarResult
+
+		switch warOutcome {
+		case gamelogic.WarOutcomeNotInvolved:
+			ackType = pubsub.NackRequeue
+		case gamelogic.WarOutcomeNoUnits:
+			ackType = pubsub.NackDiscard
+		case gamelogic.WarOutcomeOpponentWon, gamelogic.WarOutcomeYouWon:
+			logMessage = fmt.Sprintf("%s won a war against %s.", winner, loser)
+			result = rw.Result(gameID, winner)
+			ackType = pubsub.Ack
+		case gamelogic.WarOutcomeDraw:
+			logMessage = fmt.Sprintf("A war between %s and %s resulted in a draw", winner, loser)
+			result = rw.Result(gameID, "")
+			ackType = pubsub.Ack
+		default:
+			fmt.Fprintln(gs.Output(), "error: unknown war outcome")
+			ackType = pubsub.NackDiscard
+		}
+
+		if warOutcome == gamelogic.WarOutcomeOpponentWon || warOutcome == gamelogic.WarOutcomeDraw {
+			// the server must stop showing us units we just lost
+			if err := publishArmyReport(ch, gs, gameID); err != nil {
+				fmt.Fprintf(gs.Output(), "publish error: %v\n", err)
+			}
+		}
+
+		if ackType == pubsub.Ack {
+			log := routing.GameLog{
+				CurrentTime: time.Now(),
+				Message:     logMessage,
+				Username:    gs.GetUsername(),
+				GameID:      gameID,
+				Type:        routing.LogTypeWar,
+				Severity:    routing.LogSeverityInfo,
+				Fields:      result.Fields(),
+			}
+
+			if err := publishGameLog(ch, gameID, log); err != nil {
+				ackType = pubsub.NackRequeue
+			} else if err := publishWarResult(ch, gameID, sess, result); err != nil {
+				fmt.Fprintf(gs.Output(), "publish error: %v\n", err)
+				ackType = pubsub.NackRequeue
+			}
+		}
+		return ackType
+	}
+}
+
+func handlerMove(gs *gamelogic.GameState, publishCh *amqp.Channel, gameID string) func(gamelogic.ArmyMove) pubsub.Acktype {
+	return func(mv gamelogic.ArmyMove) pubsub.Acktype {
+		return resolveMove(gs, publishCh, gameID, mv)
+	}
+}
+
+// handlerTurns resolves the other players' orders only after our own have
+// been carried out, so a turn ends the same way regardless of message order.
+func handlerTurns(gs *gamelogic.GameState, publishCh *amqp.Channel, gameID string) func(gamelogic.TurnTick) pubsub.Acktype {
+	return func(tick gamelogic.TurnTick) pubsub.Acktype {
+		for _, mv := range gs.HandleTurnTick(tick) {
+			if resolveMove(gs, publishCh, gameID, mv) == pubsub.NackRequeue {
+				return pubsub.NackRequeue
+			}
+		}
+		return pubsub.Ack
+	}
+}
+
+func handlerGameOver(gs *gamelogic.GameState) func(gamelogic.GameOver) pubsub.Acktype {
+	return func(over gamelogic.GameOver) pubsub.Acktype {
+		gs.HandleGameOver(over)
+		return pubsub.Ack
+	}
+}
+
+func handlerDiplomacy(gs *gamelogic.GameState) func(routing.Diplomacy) pubsub.Acktype {
+	return func(d routing.Diplomacy) pubsub.Acktype {
+		gs.HandleDiplomacy(d)
+		return pubsub.Ack
+	}
+}
+
+func handlerIncome(gs *gamelogic.GameState, publishCh *amqp.Channel) func(gamelogic.IncomeTick) pubsub.Acktype {
+	return func(tick gamelogic.IncomeTick) pubsub.Acktype {
+		report, ok := gs.HandleIncomeTick(tick)
+		if !ok {
+			return pubsub.NackDiscard
+		}
+		rk := routing.ResourceReportKey(report.GameID, report.Player.Username)
+		if err := pubsub.PublishJSON(publishCh, routing.ExchangePerilServer, rk, report); err != nil {
+			fmt.Fprintf(gs.Output(), "publish error: %v\n", err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+}
+
+func resolveMove(gs *gamelogic.GameState, publishCh *amqp.Channel, gameID string, mv gamelogic.ArmyMove) pubsub.Acktype {
+	moveOutcome := gs.HandleMove(mv)
+
+	switch moveOutcome {
+	case gamelogic.MoveOutcomeSamePlayer:
+		return pubsub.NackDiscard
+	case gamelogic.MoveOutComeSafe:
+		return pubsub.Ack
+	case gamelogic.MoveOutcomeMakeWar:
+		// the server adds our allies before the war reaches the attacker
+		rk := routing.WarDeclarationKey(gameID, gs.GetUsername())
+		rw := gamelogic.RecognitionOfWar{
+			Attacker: mv.Player,          // the mover
+			Defender: gs.GetPlayerSnap(), // “you”
+		}
+		if err := pubsub.PublishJSON(
+			publishCh,
+			routing.ExchangePerilServer,
+			rk,
+			rw,
+		); err != nil {
+			fmt.Fprintf(gs.Output(), "publish error: %v\n", err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	default:
+		fmt.Fprintln(gs.Output(), "error: unknown move outcome")
+		return pubsub.NackDiscard
+	}
+}
+
+func publishArmyReport(ch *amqp.Channel, gs *gamelogic.GameState, gameID string) error {
+	p := gs.GetPlayerSnap()
+	return pubsub.PublishJSON(ch, routing.ExchangePerilServer, routing.ArmyReportKey(gameID, p.Username), gamelogic.ArmyReport{
+		GameID: gameID,
+		Player: p,
+	})
+}
+
+func publishWarResult(ch *amqp.Channel, gameID string, sess Session, result gamelogic.WarResult) error {
+	return pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.WarResultKey(gameID, sess.Username), gamelogic.WarResultReport{
+		Username: sess.Username,
+		Token:    sess.Token,
+		Result:   result,
+	})
+}
+
+func publishGameLog(ch *amqp.Channel, gameID string, log routing.GameLog) error {
+	routingKey := routing.GameLogKey(gameID, log.Username)
+	err := pubsub.PublishGob(ch, routing.ExchangePerilTopic, routingKey, log)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Lobby sends a player's lobby requests and follows the game they are
+// forming until it starts.
+type Lobby struct {
+	c        *Client
+	game     routing.GameSummary
+	boundTo  map[string]bool
+	joined   chan routing.GameSummary
+	started  chan routing.PlayingState
+	failures chan string
+	mu       *sync.Mutex
+}
+
+// EnterLobby announces the player to the server lobby.
+func (c *Client) EnterLobby() (*Lobby, error) {
+	l := &Lobby{
+		c:        c,
+		boundTo:  map[string]bool{},
+		joined:   make(chan routing.GameSummary, 1),
+		started:  make(chan routing.PlayingState, 1),
+		failures: make(chan string, 1),
+		mu:       &sync.Mutex{},
+	}
+
+	key := routing.LobbyUpdatesKey(c.sess.Username)
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilDirect, key, key, pubsub.QueueTransient, observed(c, EventLobby, l.handlerUpdate()))
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe to lobby updates: %v", err)
+	}
+	if err := l.send(routing.LobbyActionAnnounce, "", 0); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Lobby) List() error {
+	return l.send(routing.LobbyActionList, "", 0)
+}
+
+func (l *Lobby) Create(size int) error {
+	return l.send(routing.LobbyActionCreate, "", size)
+}
+
+func (l *Lobby) AutoMatch(size int) error {
+	return l.send(routing.LobbyActionAutoMatch, "", size)
+}
+
+func (l *Lobby) Join(gameID string) error {
+	if err := routing.ValidateGameID(gameID); err != nil {
+		return err
+	}
+	return l.send(routing.LobbyActionJoin, gameID, 0)
+}
+
+func (l *Lobby) Leave() error {
+	l.mu.Lock()
+	l.game = routing.GameSummary{}
+	l.mu.Unlock()
+	return l.send(routing.LobbyActionLeave, "", 0)
+}
+
+// AwaitGame waits until the server has put the player in a game.
+func (l *Lobby) AwaitGame(timeout time.Duration) (routing.GameSummary, error) {
+	select {
+	case g := <-l.joined:
+		return g, nil
+	case msg := <-l.failures:
+		return routing.GameSummary{}, errors.New(msg)
+	case <-time.After(timeout):
+		return routing.GameSummary{}, errors.New("timed out waiting for a game")
+	}
+}
+
+// Ready binds the game's pause and turn queues before telling the server we
+// are ready, so neither the starting PlayingState nor the first turn can be
+// missed, then waits for the start.
+func (l *Lobby) Ready() (routing.PlayingState, error) {
+	l.mu.Lock()
+	gameID := l.game.GameID
+	bound := l.boundTo[gameID]
+	l.mu.Unlock()
+	if gameID == "" {
+		return routing.PlayingState{}, errors.New("join or create a game first")
+	}
+
+	l.c.gs.SetGameID(gameID)
+	if !bound {
+		if err := l.c.subscribePause(l.started); err != nil {
+			return routing.PlayingState{}, err
+		}
+		if err := l.c.subscribeTurns(gameID); err != nil {
+			return routing.PlayingState{}, err
+		}
+		l.mu.Lock()
+		l.boundTo[gameID] = true
+		l.mu.Unlock()
+	}
+	select {
+	case <-l.failures:
+	default:
+	}
+	if err := l.send(routing.LobbyActionReady, gameID, 0); err != nil {
+		return routing.PlayingState{}, err
+	}
+
+	fmt.Fprintln(l.c.gs.Output(), "Waiting for the other players to ready up (Ctrl+C to quit)...")
+	select {
+	case ps := <-l.started:
+		return ps, nil
+	case msg := <-l.failures:
+		return routing.PlayingState{}, errors.New(msg)
+	}
+}
+
+func (l *Lobby) send(action, gameID string, size int) error {
+	req := routing.LobbyRequest{
+		Username: l.c.sess.Username,
+		Token:    l.c.sess.Token,
+		Action:   action,
+		GameID:   gameID,
+		Size:     size,
+	}
+	if err := pubsub.PublishJSON(l.c.ch, routing.ExchangePerilTopic, routing.LobbyKey(req.Username), req); err != nil {
+		return fmt.Errorf("could not send lobby request: %v", err)
+	}
+	return nil
+}
+
+func (l *Lobby) handlerUpdate() func(routing.LobbyUpdate) pubsub.Acktype {
+	return func(update routing.LobbyUpdate) pubsub.Acktype {
+		fmt.Fprintln(l.c.gs.Output())
+		switch update.Event {
+		case routing.LobbyEventGames:
+			if len(update.Games) == 0 {
+				fmt.Fprintln(l.c.gs.Output(), "No open games. Create one with: create <size>")
+			}
+			for _, g := range update.Games {
+				state := ""
+				if g.Started {
+					state = " (started)"
+				}
+				fmt.Fprintf(l.c.gs.Output(), "* %s%s: %d/%d players %v\n", g.GameID, state, len(g.Players), g.Size, g.Players)
+			}
+		case routing.LobbyEventJoined, routing.LobbyEventLeft, routing.LobbyEventReadyCheck:
+			l.mu.Lock()
+			l.game = update.Game
+			l.mu.Unlock()
+			select {
+			case <-l.joined:
+			default:
+			}
+			l.joined <- update.Game
+			g := update.Game
+			fmt.Fprintf(l.c.gs.Output(), "Game %s: %d/%d players %v\n", g.GameID, len(g.Players), g.Size, g.Players)
+			if update.Event == routing.LobbyEventReadyCheck {
+				fmt.Fprintf(l.c.gs.Output(), "Ready check: %d/%d ready %v. Type 'ready' when you are.\n", len(g.Ready), g.Size, g.Ready)
+			}
+		case routing.LobbyEventStarted:
+			// the game itself starts from the PlayingState on the pause queue
+		case routing.LobbyEventError:
+			fmt.Fprintf(l.c.gs.Output(), "lobby error: %s\n", update.Error)
+			select {
+			case l.failures <- update.Error:
+			default:
+			}
+		}
+		return pubsub.Ack
+	}
+}
+
+func (c *Client) subscribePause(started chan<- routing.PlayingState) error {
+	gameID := c.gs.GetGameID()
+	pauseQueue := routing.PauseQueue(gameID, c.sess.Username)
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseGameKey(gameID), pubsub.QueueTransient, observed(c, EventPause, handlerPause(c.gs, started)))
+	if err != nil {
+		return fmt.Errorf("could not subscribe to pauses: %v", err)
+	}
+	// server-wide pauses still use the unscoped key
+	if err := pubsub.AddBinding(c.conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseKey); err != nil {
+		return fmt.Errorf("could not bind pause queue: %v", err)
+	}
+	return nil
+}
+
+// subscribeTurns follows the turn clock. Games without turns never publish
+// on these keys, so the queue stays empty for them.
+func (c *Client) subscribeTurns(gameID string) error {
+	username := c.sess.Username
+	queue := routing.TurnsQueue(gameID, username)
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, queue, routing.TurnsKey(gameID), pubsub.QueueTransient, observed(c, EventTurn, handlerTurns(c.gs, c.ch, gameID)))
+	if err != nil {
+		return fmt.Errorf("could not subscribe to turns: %v", err)
+	}
+	if err := pubsub.AddBinding(c.conn, routing.ExchangePerilTopic, queue, routing.TurnsPlayerKey(gameID, username)); err != nil {
+		return fmt.Errorf("could not bind turns queue: %v", err)
+	}
+	return nil
+}
+
+func handlerPause(gs *gamelogic.GameState, started chan<- routing.PlayingState) func(routing.PlayingState) pubsub.Acktype {
+	return func(ps routing.PlayingState) pubsub.Acktype {
+		gs.HandlePause(ps)
+		if len(ps.Roster) > 0 && ps.GameID == gs.GetGameID() {
+			select {
+			case started <- ps:
+			default:
+			}
+		}
+		return pubsub.Ack
+	}
+}
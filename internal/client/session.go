@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const registerTimeout = 5 * time.Second
+
+var ErrNoServer = errors.New("the server did not answer the registration, is it running?")
+
+// RejectedError is returned when the server refuses a username. Another name
+// may still be accepted.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+type Session struct {
+	Username          string
+	Token             string
+	HeartbeatInterval time.Duration
+	Spectator         bool
+	// brokerUser and brokerPassword are the broker login the server made
+	// for the player, which Connect switches to.
+	brokerUser     string
+	brokerPassword string
+}
+
+// PlayerConnString is where players connect to register. Everything after
+// that goes over the connection Connect returns.
+var PlayerConnString = fmt.Sprintf("amqp://%s:%s@localhost:5672/", routing.PlayerUser, routing.PlayerPassword)
+
+// Connect swaps the connection a player registered on for one logged in as
+// the broker user the server made for them, which is the only one allowed
+// to read their queues. Spectators keep the connection they have, and so
+// does a player if the new one fails, so the caller can still close it.
+func Connect(conn *amqp.Connection, connName string, s Session) (*amqp.Connection, error) {
+	if s.brokerUser == "" {
+		return conn, nil
+	}
+	connString := fmt.Sprintf("amqp://%s:%s@localhost:5672/", url.PathEscape(s.brokerUser), url.PathEscape(s.brokerPassword))
+	player, err := pubsub.DialNamed(connString, connName)
+	if err != nil {
+		return conn, fmt.Errorf("could not connect as %s: %v", s.brokerUser, err)
+	}
+	conn.Close()
+	return player, nil
+}
+
+// Register asks the server to reserve username for this connection.
+func Register(conn *amqp.Connection, connName, username string) (Session, error) {
+	return register(conn, connName, username, false)
+}
+
+// RegisterSpectator reserves username for watching games instead of playing.
+func RegisterSpectator(conn *amqp.Connection, connName, username string) (Session, error) {
+	return register(conn, connName, username, true)
+}
+
+func register(conn *amqp.Connection, connName, username string, spectator bool) (Session, error) {
+	if err := routing.ValidateUsername(username); err != nil {
+		return Session{}, &RejectedError{Reason: err.Error()}
+	}
+	resp, err := pubsub.CallJSON[routing.RegisterRequest, routing.RegisterResponse](
+		conn,
+		routing.ExchangePerilRPC,
+		routing.RegisterKey,
+		routing.RegisterRequest{Username: username, ConnectionName: connName, Spectator: spectator},
+		registerTimeout,
+	)
+	if errors.Is(err, pubsub.ErrTimeout) {
+		return Session{}, ErrNoServer
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("could not register: %v", err)
+	}
+	if resp.Error != "" {
+		return Session{}, &RejectedError{Reason: resp.Error}
+	}
+	return Session{
+		Username:          username,
+		Token:             resp.Token,
+		HeartbeatInterval: resp.HeartbeatInterval,
+		Spectator:         spectator,
+		brokerUser:        resp.BrokerUser,
+		brokerPassword:    resp.BrokerPassword,
+	}, nil
+}
+
+// sendHeartbeats keeps the session's name reserved and the player shown as
+// online until done is closed.
+func sendHeartbeats(ch *amqp.Channel, s Session, gs *gamelogic.GameState, done <-chan struct{}) {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		if err := publishHeartbeat(ch, s, gs, false); err != nil {
+			fmt.Fprintf(gs.Output(), "heartbeat error: %v\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func publishHeartbeat(ch *amqp.Channel, s Session, gs *gamelogic.GameState, leaving bool) error {
+	hb := routing.Heartbeat{
+		Username: s.Username,
+		Token:    s.Token,
+		GameID:   gs.GetGameID(),
+		SentAt:   time.Now(),
+		Leaving:  leaving,
+	}
+	return pubsub.PublishJSON(ch, routing.ExchangePerilTopic, routing.HeartbeatKey(s.Username), hb)
+}
+
+func handlerPresence(gs *gamelogic.GameState) func(routing.PresenceEvent) pubsub.Acktype {
+	return func(pe routing.PresenceEvent) pubsub.Acktype {
+		if pe.Username == gs.GetUsername() {
+			return pubsub.Ack
+		}
+		gs.HandlePresence(pe)
+		return pubsub.Ack
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/world"
+)
+
+const spectateTimeout = 5 * time.Second
+
+// Spectate watches a started game without playing in it. The client follows
+// its own copies of the game's moves, army reports, wars, logs, pauses and
+// result, and its only commands show the board.
+func (c *Client) Spectate(gameID string) error {
+	if !c.sess.Spectator {
+		return errors.New("register as a spectator to watch games")
+	}
+	if err := routing.ValidateGameID(gameID); err != nil {
+		return err
+	}
+	resp, err := pubsub.CallJSON[gamelogic.SpectateRequest, gamelogic.SpectateResponse](
+		c.conn,
+		routing.ExchangePerilRPC,
+		routing.SpectateKey,
+		gamelogic.SpectateRequest{
+			Username: c.sess.Username,
+			Token:    c.sess.Token,
+			GameID:   gameID,
+		},
+		spectateTimeout,
+	)
+	if err != nil {
+		return fmt.Errorf("could not watch game %s: %v", gameID, err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	c.gameID = gameID
+	c.gs.SetGameID(gameID)
+	c.watched = world.New()
+	if err := c.watch(gameID); err != nil {
+		return err
+	}
+	for _, p := range resp.Armies {
+		c.watched.Add(gameID, p)
+	}
+	c.commands = c.newSpectatorCommands()
+	fmt.Fprintf(c.gs.Output(), "Watching game %s with %d player(s) %v\n", gameID, len(resp.Game.Players), resp.Game.Players)
+	return nil
+}
+
+func (c *Client) watch(gameID string) error {
+	username := c.sess.Username
+	queue := func(prefix string) string {
+		return routing.SpectatorQueue(prefix, gameID, username)
+	}
+
+	err := pubsub.SubscribeJSON(c.conn, routing.ExchangePerilServer, queue(routing.ArmyMovesPrefix), routing.ArmyMovesBinding(gameID), pubsub.QueueTransient, observed(c, EventMove, c.handlerWatchMove()))
+	if err != nil {
+		return err
+	}
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilServer, queue(routing.ArmyReportsPrefix), routing.ArmyReportGameBinding(gameID), pubsub.QueueTransient, observed(c, EventArmyReport, c.handlerWatchReport()))
+	if err != nil {
+		return err
+	}
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, queue(routing.WarRecognitionsPrefix), routing.WarRecognitionsBinding(gameID), pubsub.QueueTransient, observed(c, EventWar, c.handlerWatchWar()))
+	if err != nil {
+		return err
+	}
+	err = pubsub.SubscribeGob(c.conn, routing.ExchangePerilTopic, queue(routing.GameLogSlug), routing.GameLogGameBinding(gameID), pubsub.QueueTransient, observed(c, EventLog, c.handlerWatchLog()))
+	if err != nil {
+		return err
+	}
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilTopic, queue(routing.GameOverPrefix), routing.GameOverKey(gameID), pubsub.QueueTransient, observed(c, EventGameOver, handlerGameOver(c.gs)))
+	if err != nil {
+		return err
+	}
+
+	pauseQueue := queue(routing.PauseKey)
+	err = pubsub.SubscribeJSON(c.conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseGameKey(gameID), pubsub.QueueTransient, observed(c, EventPause, handlerPause(c.gs, nil)))
+	if err != nil {
+		return err
+	}
+	return pubsub.AddBinding(c.conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseKey)
+}
+
+// Armies lists the last known army of every player in the watched game.
+func (c *Client) Armies() []gamelogic.Player {
+	if c.watched == nil {
+		return nil
+	}
+	players := []gamelogic.Player{}
+	for _, a := range c.watched.Armies(c.gameID) {
+		players = append(players, a.Player)
+	}
+	return players
+}
+
+func (c *Client) handlerWatchMove() func(gamelogic.ArmyMove) pubsub.Acktype {
+	return func(mv gamelogic.ArmyMove) pubsub.Acktype {
+		c.watched.Update(mv.GameID, mv.Player)
+		fmt.Fprintf(c.gs.Output(), "%s moved %d unit(s) to %s\n", mv.Player.Username, len(mv.Units), mv.ToLocation)
+		return pubsub.Ack
+	}
+}
+
+func (c *Client) handlerWatchReport() func(gamelogic.ArmyReport) pubsub.Acktype {
+	return func(r gamelogic.ArmyReport) pubsub.Acktype {
+		c.watched.Update(r.GameID, r.Player)
+		return pubsub.Ack
+	}
+}
+
+func (c *Client) handlerWatchWar() func(gamelogic.RecognitionOfWar) pubsub.Acktype {
+	return func(rw gamelogic.RecognitionOfWar) pubsub.Acktype {
+		defer fmt.Fprintln(c.gs.Output(), "------------------------")
+		fmt.Fprintln(c.gs.Output())
+		fmt.Fprintln(c.gs.Output(), "==== War Declared ====")
+		fmt.Fprintf(c.gs.Output(), "%s has declared war on %s in %s!\n", rw.Attacker.Username, rw.Defender.Username, rw.Location())
+		return pubsub.Ack
+	}
+}
+
+func (c *Client) handlerWatchLog() func(routing.GameLog) pubsub.Acktype {
+	return func(l routing.GameLog) pubsub.Acktype {
+		fmt.Fprintf(c.gs.Output(), "%s: %s\n", l.Username, l.Message)
+		return pubsub.Ack
+	}
+}
+
+func (c *Client) newSpectatorCommands() *gamelogic.Registry {
+	r := gamelogic.NewRegistry("Spectator commands:")
+	r.Register(gamelogic.Command{
+		Name:    "world",
+		Aliases: []string{"map"},
+		Run: func(gamelogic.Args) error {
+			gamelogic.PrintWorld(c.gs.Output(), c.Armies())
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name: "armies",
+		Run: func(gamelogic.Args) error {
+			gamelogic.PrintArmies(c.gs.Output(), c.Armies())
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "status",
+		Aliases: []string{"st"},
+		Run: func(gamelogic.Args) error {
+			c.gs.CommandWatchStatus()
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "leaderboard",
+		Aliases: []string{"top"},
+		Args:    []gamelogic.Arg{{Name: "n", Type: gamelogic.ArgInt, Optional: true}},
+		Run:     c.leaderboard,
+	})
+	r.Register(gamelogic.Command{
+		Name:    "quit",
+		Aliases: []string{"exit"},
+		Run: func(gamelogic.Args) error {
+			c.Leave()
+			gamelogic.PrintQuit()
+			c.quitting = true
+			return nil
+		},
+	})
+	r.Register(gamelogic.Command{
+		Name:    "help",
+		Aliases: []string{"?"},
+		Run: func(gamelogic.Args) error {
+			r.FprintHelp(c.gs.Output())
+			return nil
+		},
+	})
+	return r
+}
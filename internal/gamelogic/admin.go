@@ -0,0 +1,28 @@
+package gamelogic
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func (gs *GameState) HandleAnnouncement(a routing.Announcement) {
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	fmt.Fprintln(gs.Output(), "==== Server Announcement ====")
+	fmt.Fprintln(gs.Output(), a.Message)
+}
+
+func (gs *GameState) HandleAdminNotice(n routing.AdminNotice) {
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	switch n.Action {
+	case routing.AdminActionBan:
+		fmt.Fprintln(gs.Output(), "==== You Have Been Banned ====")
+	default:
+		fmt.Fprintln(gs.Output(), "==== You Have Been Kicked ====")
+	}
+	if n.Reason != "" {
+		fmt.Fprintf(gs.Output(), "Reason: %s\n", n.Reason)
+	}
+}
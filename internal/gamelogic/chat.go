@@ -0,0 +1,63 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// CommandSay reads [global|alliance] <message>. Without a channel the
+// message goes to everyone in the game.
+func (gs *GameState) CommandSay(message []string) (routing.ChatMessage, error) {
+	msg := routing.ChatMessage{
+		Username: gs.GetUsername(),
+		GameID:   gs.GetGameID(),
+		Channel:  routing.ChatChannelGame,
+		At:       time.Now(),
+	}
+	args := message
+	switch args[0] {
+	case routing.ChatChannelGlobal:
+		msg.Channel = routing.ChatChannelGlobal
+		args = args[1:]
+	case routing.ChatChannelAlliance:
+		msg.Channel = routing.ChatChannelAlliance
+		msg.To = gs.getAllies()
+		if len(msg.To) == 0 {
+			return routing.ChatMessage{}, errors.New("you have no allies to talk to")
+		}
+		args = args[1:]
+	}
+	msg.Text = strings.Join(args, " ")
+	if msg.Text == "" {
+		return routing.ChatMessage{}, errors.New("usage: say [global|alliance] <message>")
+	}
+	return msg, nil
+}
+
+func (gs *GameState) CommandWhisper(to, text string) (routing.ChatMessage, error) {
+	if to == gs.GetUsername() {
+		return routing.ChatMessage{}, errors.New("you can not whisper to yourself")
+	}
+	return routing.ChatMessage{
+		Username: gs.GetUsername(),
+		GameID:   gs.GetGameID(),
+		Channel:  routing.ChatChannelDirect,
+		To:       []string{to},
+		Text:     text,
+		At:       time.Now(),
+	}, nil
+}
+
+func (gs *GameState) HandleChat(msg routing.ChatMessage) {
+	fmt.Fprintln(gs.Output())
+	switch msg.Channel {
+	case routing.ChatChannelDirect:
+		fmt.Fprintf(gs.Output(), "[whisper] %s: %s\n", msg.Username, msg.Text)
+	default:
+		fmt.Fprintf(gs.Output(), "[%s] %s: %s\n", msg.Channel, msg.Username, msg.Text)
+	}
+}
@@ -0,0 +1,317 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lineedit"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgDuration
+	ArgLocation
+	ArgRank
+	ArgUnitID
+	ArgGameID
+	ArgUsername
+)
+
+// Arg declares one argument of a command. Only the last argument may be
+// Variadic, which makes it take every remaining word.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	Variadic bool
+}
+
+type Command struct {
+	Name    string
+	Aliases []string
+	Args    []Arg
+	// Usage replaces the usage generated from Args for commands whose
+	// arguments are parsed by hand.
+	Usage string
+	// Help is printed under the usage, for anything the usage can't say.
+	Help    string
+	Example string
+	Run     func(args Args) error
+}
+
+func (c *Command) usage() string {
+	if c.Usage != "" {
+		return c.Name + " " + c.Usage
+	}
+	parts := []string{c.Name}
+	for _, a := range c.Args {
+		name := a.Name
+		if a.Variadic {
+			name += "..."
+		}
+		if a.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Args holds the validated arguments of a command, keyed by name.
+type Args struct {
+	words  []string
+	values map[string][]string
+}
+
+// Words returns the command line as typed, including the command name.
+func (a Args) Words() []string {
+	return a.words
+}
+
+func (a Args) Has(name string) bool {
+	return len(a.values[name]) > 0
+}
+
+func (a Args) String(name string) string {
+	return strings.Join(a.values[name], " ")
+}
+
+func (a Args) Strings(name string) []string {
+	return a.values[name]
+}
+
+func (a Args) Int(name string) int {
+	n, _ := strconv.Atoi(a.String(name))
+	return n
+}
+
+func (a Args) Duration(name string) time.Duration {
+	d, _ := time.ParseDuration(a.String(name))
+	return d
+}
+
+func (a Args) Location(name string) Location {
+	return Location(a.String(name))
+}
+
+func (a Args) Rank(name string) UnitRank {
+	return UnitRank(a.String(name))
+}
+
+func (a Args) UnitIDs(name string) []int {
+	ids := []int{}
+	for _, word := range a.values[name] {
+		id, _ := strconv.Atoi(word)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// CommandError is returned by Registry.Run when a command fails.
+type CommandError struct {
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s error: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Registry dispatches REPL commands and prints their help.
+type Registry struct {
+	title    string
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func NewRegistry(title string) *Registry {
+	return &Registry{
+		title:  title,
+		byName: map[string]*Command{},
+	}
+}
+
+func (r *Registry) Register(c Command) {
+	cmd := &c
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		if _, ok := r.byName[name]; ok {
+			panic(fmt.Sprintf("command %q registered twice", name))
+		}
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+}
+
+// Names lists every command name and alias, sorted.
+func (r *Registry) Names() []string {
+	names := []string{}
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run parses words against the command they name and runs it. Empty input
+// does nothing.
+func (r *Registry) Run(words []string) error {
+	if len(words) == 0 {
+		return nil
+	}
+	cmd, ok := r.byName[words[0]]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, words[0])
+	}
+	args, err := cmd.parse(words)
+	if err != nil {
+		return &CommandError{Command: cmd.Name, Err: err}
+	}
+	if err := cmd.Run(args); err != nil {
+		return &CommandError{Command: cmd.Name, Err: err}
+	}
+	return nil
+}
+
+// Completer completes command names, and then each argument from the values
+// of its type. values may return nil for types it knows nothing about.
+func (r *Registry) Completer(values func(ArgType) []string) lineedit.Completer {
+	return func(words []string, prefix string) []string {
+		if len(words) == 0 {
+			return r.Names()
+		}
+		cmd, ok := r.byName[words[0]]
+		if !ok || len(cmd.Args) == 0 {
+			return nil
+		}
+		i := len(words) - 1
+		if i >= len(cmd.Args) {
+			if !cmd.Args[len(cmd.Args)-1].Variadic {
+				return nil
+			}
+			i = len(cmd.Args) - 1
+		}
+		a := cmd.Args[i]
+		if a.Type == ArgString && strings.Contains(a.Name, "|") {
+			return strings.Split(a.Name, "|")
+		}
+		return values(a.Type)
+	}
+}
+
+// CompletionValues offers the values every game knows: locations and ranks.
+func CompletionValues(t ArgType) []string {
+	values := []string{}
+	switch t {
+	case ArgLocation:
+		for loc := range getAllLocations() {
+			values = append(values, string(loc))
+		}
+	case ArgRank:
+		for rank := range getAllRanks() {
+			values = append(values, string(rank))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (c *Command) parse(words []string) (Args, error) {
+	args := Args{
+		words:  words,
+		values: map[string][]string{},
+	}
+	rest := words[1:]
+	for _, a := range c.Args {
+		if len(rest) == 0 {
+			if a.Optional {
+				continue
+			}
+			return Args{}, fmt.Errorf("usage: %s", c.usage())
+		}
+		take := rest[:1]
+		if a.Variadic {
+			take = rest
+		}
+		for _, word := range take {
+			if err := validateArg(a, word); err != nil {
+				return Args{}, err
+			}
+		}
+		args.values[a.Name] = take
+		rest = rest[len(take):]
+	}
+	if len(rest) > 0 {
+		return Args{}, fmt.Errorf("usage: %s", c.usage())
+	}
+	return args, nil
+}
+
+func validateArg(a Arg, word string) error {
+	switch a.Type {
+	case ArgInt:
+		if _, err := strconv.Atoi(word); err != nil {
+			return fmt.Errorf("%s is not a valid %s", word, a.Name)
+		}
+	case ArgDuration:
+		if d, err := time.ParseDuration(word); err != nil || d <= 0 {
+			return fmt.Errorf("%s is not a valid duration", word)
+		}
+	case ArgLocation:
+		if _, ok := getAllLocations()[Location(word)]; !ok {
+			return fmt.Errorf("%s is not a valid location", word)
+		}
+	case ArgRank:
+		if _, ok := getAllRanks()[UnitRank(word)]; !ok {
+			return fmt.Errorf("%s is not a valid unit", word)
+		}
+	case ArgUnitID:
+		if id, err := strconv.Atoi(word); err != nil || id < 1 {
+			return fmt.Errorf("%s is not a valid unit ID", word)
+		}
+	case ArgGameID:
+		return routing.ValidateGameID(word)
+	case ArgUsername:
+		return routing.ValidateUsername(word)
+	}
+	return nil
+}
+
+func (r *Registry) PrintHelp() {
+	r.FprintHelp(os.Stdout)
+}
+
+// FprintHelp lists the commands to w.
+func (r *Registry) FprintHelp(w io.Writer) {
+	fmt.Fprintln(w, r.title)
+	for _, c := range r.commands {
+		line := "* " + c.usage()
+		if len(c.Aliases) > 0 {
+			line += fmt.Sprintf(" (also: %s)", strings.Join(c.Aliases, ", "))
+		}
+		fmt.Fprintln(w, line)
+		if c.Help != "" {
+			fmt.Fprintf(w, "    %s\n", c.Help)
+		}
+		if c.Example != "" {
+			fmt.Fprintln(w, "    example:")
+			fmt.Fprintf(w, "    %s\n", c.Example)
+		}
+	}
+}
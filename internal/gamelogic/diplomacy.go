@@ -0,0 +1,158 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// CommandAlliance proposes, accepts or breaks an alliance and returns the
+// message to send to the other player.
+func (gs *GameState) CommandAlliance(action, other string) (routing.Diplomacy, error) {
+	if gs.IsOver() {
+		return routing.Diplomacy{}, errGameOver
+	}
+	username := gs.GetUsername()
+	if other == username {
+		return routing.Diplomacy{}, errors.New("you can not ally with yourself")
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	switch action {
+	case routing.DiplomacyPropose:
+		if gs.allies[other] {
+			return routing.Diplomacy{}, fmt.Errorf("you are already allied with %s", other)
+		}
+		gs.proposedTo[other] = true
+	case routing.DiplomacyAccept:
+		if !gs.proposedBy[other] {
+			return routing.Diplomacy{}, fmt.Errorf("%s has not proposed an alliance", other)
+		}
+		delete(gs.proposedBy, other)
+		gs.record(Event{Type: EventAllianceChanged, Ally: other, Allied: true})
+	case routing.DiplomacyBreak:
+		if !gs.allies[other] {
+			return routing.Diplomacy{}, fmt.Errorf("you are not allied with %s", other)
+		}
+		gs.record(Event{Type: EventAllianceChanged, Ally: other, Allied: false})
+	default:
+		return routing.Diplomacy{}, fmt.Errorf("%s is not a diplomacy action, use propose, accept or break", action)
+	}
+	return routing.Diplomacy{
+		GameID: gs.GameID,
+		From:   username,
+		To:     other,
+		Action: action,
+		At:     time.Now(),
+	}, nil
+}
+
+func (gs *GameState) HandleDiplomacy(d routing.Diplomacy) {
+	if d.GameID != gs.GetGameID() || d.To != gs.GetUsername() {
+		return
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	fmt.Fprintln(gs.Output(), "==== Diplomacy ====")
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	switch d.Action {
+	case routing.DiplomacyPropose:
+		if gs.allies[d.From] {
+			fmt.Fprintf(gs.Output(), "%s proposed an alliance, but you are already allies.\n", d.From)
+			return
+		}
+		gs.proposedBy[d.From] = true
+		fmt.Fprintf(gs.Output(), "%s proposes an alliance.\n", d.From)
+		fmt.Fprintf(gs.Output(), "Use 'alliance accept %s' to accept it.\n", d.From)
+	case routing.DiplomacyAccept:
+		if !gs.proposedTo[d.From] {
+			fmt.Fprintf(gs.Output(), "%s accepted an alliance you never proposed.\n", d.From)
+			return
+		}
+		delete(gs.proposedTo, d.From)
+		gs.record(Event{Type: EventAllianceChanged, Ally: d.From, Allied: true})
+		fmt.Fprintf(gs.Output(), "%s accepted your alliance. Your units can now share locations.\n", d.From)
+	case routing.DiplomacyBreak:
+		delete(gs.proposedTo, d.From)
+		delete(gs.proposedBy, d.From)
+		if gs.allies[d.From] {
+			gs.record(Event{Type: EventAllianceChanged, Ally: d.From, Allied: false})
+		}
+		fmt.Fprintf(gs.Output(), "%s broke your alliance!\n", d.From)
+	default:
+		fmt.Fprintf(gs.Output(), "%s sent an unknown diplomacy action: %s\n", d.From, d.Action)
+	}
+}
+
+func (gs *GameState) isAlly(username string) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.allies[username]
+}
+
+func (gs *GameState) getAllies() []string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	allies := []string{}
+	for username := range gs.allies {
+		allies = append(allies, username)
+	}
+	sort.Strings(allies)
+	return allies
+}
+
+// KnownPlayers lists the players we have met, allied or not.
+func (gs *GameState) KnownPlayers() []string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	seen := map[string]bool{}
+	for username := range gs.known {
+		seen[username] = true
+	}
+	for username := range gs.allies {
+		seen[username] = true
+	}
+	players := []string{}
+	for username := range seen {
+		players = append(players, username)
+	}
+	sort.Strings(players)
+	return players
+}
+
+// remember keeps the last army seen from another player, which is all we know
+// about where their units are.
+func (gs *GameState) remember(p Player) {
+	units := map[int]Unit{}
+	for k, v := range p.Units {
+		units[k] = v
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.known[p.Username] = Player{
+		Username: p.Username,
+		Units:    units,
+	}
+}
+
+// AlliedForces returns the last known armies of our allies.
+func (gs *GameState) AlliedForces() []Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	forces := []Player{}
+	for username := range gs.allies {
+		if p, ok := gs.known[username]; ok {
+			forces = append(forces, p)
+		}
+	}
+	sort.Slice(forces, func(i, j int) bool {
+		return forces[i].Username < forces[j].Username
+	})
+	return forces
+}
@@ -0,0 +1,105 @@
+package gamelogic
+
+import "sort"
+
+// IncomeTick is published by the server on every income interval. Standings
+// are every player's balance after the income was paid. The server keeps the
+// balances, so a client's own count never decides what it can afford.
+type IncomeTick struct {
+	GameID    string
+	Tick      int
+	Standings map[string]int
+}
+
+// ResourceReport is a player's answer to an IncomeTick. It tells the server
+// where the player's units are; the balance in it is only the player's view.
+type ResourceReport struct {
+	GameID    string
+	Tick      int
+	Player    Player
+	Resources int
+	Held      []Location
+}
+
+func (gs *GameState) GetResources() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.resources
+}
+
+// SpawnRequest asks the server to charge for a unit before it is spawned.
+type SpawnRequest struct {
+	GameID   string
+	Username string
+	Token    string
+	Location Location
+	Rank     UnitRank
+}
+
+// SpawnResponse carries the balance left after the server charged for the
+// unit.
+type SpawnResponse struct {
+	Resources int
+	Error     string
+}
+
+// setBalance must be called with gs.mu held.
+func (gs *GameState) setBalance(balance int, reason string) {
+	if balance == gs.resources {
+		return
+	}
+	gs.record(Event{Type: EventResourcesChanged, Amount: balance - gs.resources, Reason: reason})
+}
+
+// heldLocations must be called with gs.mu held.
+func (gs *GameState) heldLocations() []Location {
+	held := map[Location]struct{}{}
+	for _, u := range gs.Player.Units {
+		held[u.Location] = struct{}{}
+	}
+	locations := []Location{}
+	for loc := range held {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+// HandleIncomeTick takes the balance the server paid the player and returns
+// the report to send back to the server.
+func (gs *GameState) HandleIncomeTick(tick IncomeTick) (ResourceReport, bool) {
+	if tick.GameID != gs.GetGameID() {
+		return ResourceReport{}, false
+	}
+	gs.mu.Lock()
+	held := gs.heldLocations()
+	if balance, ok := tick.Standings[gs.Player.Username]; ok {
+		gs.setBalance(balance, "income")
+	}
+	gs.standings = map[string]int{}
+	for username, balance := range tick.Standings {
+		gs.standings[username] = balance
+	}
+	balance := gs.resources
+	gs.mu.Unlock()
+
+	return ResourceReport{
+		GameID:    tick.GameID,
+		Tick:      tick.Tick,
+		Player:    gs.GetPlayerSnap(),
+		Resources: balance,
+		Held:      held,
+	}, true
+}
+
+func (gs *GameState) getStandings() map[string]int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	standings := map[string]int{}
+	for k, v := range gs.standings {
+		standings[k] = v
+	}
+	return standings
+}
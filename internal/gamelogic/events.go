@@ -0,0 +1,219 @@
+package gamelogic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const journalsDir = "journals"
+
+type EventType string
+
+const (
+	EventUnitSpawned      EventType = "unit_spawned"
+	EventUnitMoved        EventType = "unit_moved"
+	EventUnitsDestroyed   EventType = "units_destroyed"
+	EventGamePaused       EventType = "game_paused"
+	EventStateRestored    EventType = "state_restored"
+	EventResourcesChanged EventType = "resources_changed"
+	EventGameOver         EventType = "game_over"
+	EventAllianceChanged  EventType = "alliance_changed"
+)
+
+// Event is a single mutation of a GameState. Folding a player's events in
+// sequence order always rebuilds the same state, so journals can be replayed.
+type Event struct {
+	Seq      int        `json:"seq"`
+	Time     time.Time  `json:"time"`
+	Type     EventType  `json:"type"`
+	Username string     `json:"username"`
+	Unit     *Unit      `json:"unit,omitempty"`
+	Location Location   `json:"location,omitempty"`
+	Paused   bool       `json:"paused,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+	Amount   int        `json:"amount,omitempty"`
+	Winner   string     `json:"winner,omitempty"`
+	Ally     string     `json:"ally,omitempty"`
+	Allied   bool       `json:"allied,omitempty"`
+	Snapshot *Snapshot  `json:"snapshot,omitempty"`
+}
+
+type Journal interface {
+	Append(Event) error
+}
+
+type FileJournal struct {
+	path string
+	f    *os.File
+	mu   sync.Mutex
+}
+
+func OpenJournal(username string) (*FileJournal, error) {
+	if err := os.MkdirAll(journalsDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create journals directory: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.jsonl", username, time.Now().UTC().Format("20060102T150405"))
+	path := filepath.Join(journalsDir, name)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %v", err)
+	}
+	return &FileJournal{path: path, f: f}, nil
+}
+
+func (j *FileJournal) Path() string {
+	return j.path
+}
+
+func (j *FileJournal) Append(e Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %v", err)
+	}
+	b = append(b, '\n')
+	if _, err := j.f.Write(b); err != nil {
+		return fmt.Errorf("could not write event: %v", err)
+	}
+	return nil
+}
+
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+func ReadJournal(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %v", err)
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("could not decode journal line %d: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read journal: %v", err)
+	}
+	return events, nil
+}
+
+// Fold rebuilds a GameState from a journal without journaling it again.
+func Fold(username string, events []Event) *GameState {
+	gs := NewGameState(username)
+	for _, e := range events {
+		gs.ApplyEvent(e)
+	}
+	return gs
+}
+
+func (gs *GameState) SetJournal(j Journal) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.journal = j
+}
+
+func (gs *GameState) ApplyEvent(e Event) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.apply(e)
+	if e.Seq > gs.seq {
+		gs.seq = e.Seq
+	}
+}
+
+// record must be called with gs.mu held.
+func (gs *GameState) record(e Event) {
+	gs.seq++
+	e.Seq = gs.seq
+	e.Time = time.Now()
+	e.Username = gs.Player.Username
+	gs.apply(e)
+	if gs.journal == nil {
+		return
+	}
+	if err := gs.journal.Append(e); err != nil {
+		fmt.Fprintf(gs.Output(), "journal error: %v\n", err)
+	}
+}
+
+// apply must be called with gs.mu held.
+func (gs *GameState) apply(e Event) {
+	switch e.Type {
+	case EventUnitSpawned:
+		if e.Unit == nil {
+			return
+		}
+		gs.Player.Units[e.Unit.ID] = *e.Unit
+		if e.Unit.ID >= gs.nextID {
+			gs.nextID = e.Unit.ID + 1
+		}
+	case EventUnitMoved:
+		if e.Unit == nil {
+			return
+		}
+		gs.Player.Units[e.Unit.ID] = *e.Unit
+	case EventUnitsDestroyed:
+		for k, v := range gs.Player.Units {
+			if v.Location == e.Location {
+				delete(gs.Player.Units, k)
+			}
+		}
+	case EventGamePaused:
+		gs.Paused = e.Paused
+		gs.pauseReason = e.Reason
+		gs.pausedUntil = time.Time{}
+		if e.Until != nil {
+			gs.pausedUntil = *e.Until
+		}
+	case EventStateRestored:
+		if e.Snapshot == nil {
+			return
+		}
+		units := map[int]Unit{}
+		for k, v := range e.Snapshot.Player.Units {
+			units[k] = v
+		}
+		gs.Player.Units = units
+		gs.Paused = e.Snapshot.Paused
+		gs.pauseReason = e.Snapshot.PauseReason
+		gs.pausedUntil = e.Snapshot.PausedUntil
+		gs.nextID = e.Snapshot.NextID
+		if gs.nextID < 1 {
+			gs.nextID = 1
+		}
+		gs.resources = e.Snapshot.Resources
+	case EventResourcesChanged:
+		gs.resources += e.Amount
+	case EventAllianceChanged:
+		if e.Allied {
+			gs.allies[e.Ally] = true
+		} else {
+			delete(gs.allies, e.Ally)
+		}
+	case EventGameOver:
+		gs.over = true
+		gs.winner = e.Winner
+	}
+}
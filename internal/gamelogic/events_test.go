@@ -0,0 +1,128 @@
+package gamelogic
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// state is everything a journal is meant to rebuild.
+type state struct {
+	Units       map[int]Unit
+	Paused      bool
+	PauseReason string
+	PausedUntil time.Time
+	NextID      int
+	Resources   int
+	Allies      map[string]bool
+	Over        bool
+	Winner      string
+	Seq         int
+}
+
+func stateOf(gs *GameState) state {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	units := map[int]Unit{}
+	for k, v := range gs.Player.Units {
+		units[k] = v
+	}
+	allies := map[string]bool{}
+	for k, v := range gs.allies {
+		allies[k] = v
+	}
+	return state{
+		Units:       units,
+		Paused:      gs.Paused,
+		PauseReason: gs.pauseReason,
+		PausedUntil: gs.pausedUntil,
+		NextID:      gs.nextID,
+		Resources:   gs.resources,
+		Allies:      allies,
+		Over:        gs.over,
+		Winner:      gs.winner,
+		Seq:         gs.seq,
+	}
+}
+
+func TestFoldReproducesLiveState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &FileJournal{path: path, f: f}
+
+	gs := NewGameState("alice")
+	gs.SetGameID("g1")
+	gs.SetJournal(j)
+
+	gs.addUnit(Unit{ID: 1, Rank: RankInfantry, Location: "europe"})
+	gs.addUnit(Unit{ID: 2, Rank: RankCavalry, Location: "asia"})
+	gs.addUnit(Unit{ID: 3, Rank: RankArtillery, Location: "asia"})
+	gs.UpdateUnit(Unit{ID: 1, Rank: RankInfantry, Location: "africa"})
+	gs.removeUnitsInLocation("asia")
+	gs.pauseGame("lunch", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	gs.resumeGame()
+	gs.pauseGame("admin", time.Time{})
+	gs.mu.Lock()
+	gs.setBalance(2, "spawned")
+	gs.record(Event{Type: EventAllianceChanged, Ally: "bob", Allied: true})
+	gs.record(Event{Type: EventAllianceChanged, Ally: "carol", Allied: true})
+	gs.record(Event{Type: EventAllianceChanged, Ally: "carol", Allied: false})
+	gs.mu.Unlock()
+	gs.HandleGameOver(GameOver{GameID: "g1", Winner: "bob", Allies: []string{"alice"}})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadJournal: %v", err)
+	}
+	if len(events) != 13 {
+		t.Fatalf("journal has %d events, want 13", len(events))
+	}
+	for i, e := range events {
+		if e.Seq != i+1 || e.Username != "alice" {
+			t.Errorf("event %d has seq %d and username %q", i, e.Seq, e.Username)
+		}
+	}
+
+	folded := Fold("alice", events)
+	if got, want := stateOf(folded), stateOf(gs); !reflect.DeepEqual(got, want) {
+		t.Errorf("folded state = %+v\nlive state   %+v", got, want)
+	}
+}
+
+// A journal that starts with a restored save rebuilds from the save, not from
+// a fresh state.
+func TestFoldFromRestoredSnapshot(t *testing.T) {
+	var j memoryJournal
+	gs := NewGameState("alice")
+	gs.SetJournal(&j)
+	gs.addUnit(Unit{ID: 1, Rank: RankInfantry, Location: "europe"})
+	gs.Restore(Snapshot{
+		Player:    Player{Username: "alice", Units: map[int]Unit{7: {ID: 7, Rank: RankCavalry, Location: "asia"}}},
+		NextID:    8,
+		Resources: 4,
+	})
+	gs.addUnit(Unit{ID: 8, Rank: RankInfantry, Location: "asia"})
+
+	folded := Fold("alice", []Event(j))
+	if got, want := stateOf(folded), stateOf(gs); !reflect.DeepEqual(got, want) {
+		t.Errorf("folded state = %+v\nlive state   %+v", got, want)
+	}
+	if _, ok := folded.GetUnit(1); ok {
+		t.Error("a unit from before the restore survived it")
+	}
+}
+
+type memoryJournal []Event
+
+func (j *memoryJournal) Append(e Event) error {
+	*j = append(*j, e)
+	return nil
+}
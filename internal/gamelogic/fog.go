@@ -0,0 +1,153 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArmyReport tells the server where a player's units are when that changed
+// without a move, e.g. after a spawn or a lost war.
+type ArmyReport struct {
+	GameID string
+	Player Player
+}
+
+var borders = [][2]Location{
+	{"americas", "europe"},
+	{"americas", "africa"},
+	{"americas", "asia"},
+	{"americas", "antarctica"},
+	{"europe", "africa"},
+	{"europe", "asia"},
+	{"africa", "asia"},
+	{"africa", "antarctica"},
+	{"asia", "australia"},
+	{"australia", "antarctica"},
+}
+
+// Adjacent maps every location to the locations bordering it.
+func Adjacent() map[Location][]Location {
+	adjacent := map[Location][]Location{}
+	for _, b := range borders {
+		adjacent[b[0]] = append(adjacent[b[0]], b[1])
+		adjacent[b[1]] = append(adjacent[b[1]], b[0])
+	}
+	return adjacent
+}
+
+// VisibleLocations are the locations a player occupies and their neighbours.
+func VisibleLocations(p Player) map[Location]bool {
+	adjacent := Adjacent()
+	visible := map[Location]bool{}
+	for _, u := range p.Units {
+		visible[u.Location] = true
+		for _, loc := range adjacent[u.Location] {
+			visible[loc] = true
+		}
+	}
+	return visible
+}
+
+// FilterFor strips a move down to what viewer can see. It reports false when
+// viewer can see none of it.
+func (mv ArmyMove) FilterFor(viewer Player) (ArmyMove, bool) {
+	if mv.Player.Username == viewer.Username {
+		return mv, true
+	}
+	visible := VisibleLocations(viewer)
+	filtered := ArmyMove{
+		GameID:     mv.GameID,
+		ToLocation: mv.ToLocation,
+		Player: Player{
+			Username: mv.Player.Username,
+			Units:    map[int]Unit{},
+		},
+		Units: []Unit{},
+	}
+	for id, u := range mv.Player.Units {
+		if visible[u.Location] {
+			filtered.Player.Units[id] = u
+		}
+	}
+	if visible[mv.ToLocation] {
+		filtered.Units = append(filtered.Units, mv.Units...)
+	}
+	return filtered, len(filtered.Player.Units) > 0 || len(filtered.Units) > 0
+}
+
+type sighting struct {
+	units []Unit
+	at    time.Time
+}
+
+// sight records what we can see of another player's army. Locations we can
+// not see keep their older sightings.
+func (gs *GameState) sight(p Player, at time.Time) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	seen, ok := gs.sightings[p.Username]
+	if !ok {
+		seen = map[Location]sighting{}
+		gs.sightings[p.Username] = seen
+	}
+	units := map[Location][]Unit{}
+	for _, u := range p.Units {
+		units[u.Location] = append(units[u.Location], u)
+	}
+	for loc := range VisibleLocations(gs.Player) {
+		if len(units[loc]) == 0 {
+			delete(seen, loc)
+			continue
+		}
+		seen[loc] = sighting{units: units[loc], at: at}
+	}
+}
+
+func (gs *GameState) printSightings() {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	usernames := []string{}
+	for username, seen := range gs.sightings {
+		if len(seen) > 0 && !gs.allies[username] {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+	sort.Strings(usernames)
+	fmt.Fprintln(gs.Output(), "Last known enemy positions:")
+	now := time.Now()
+	for _, username := range usernames {
+		for _, loc := range Locations() {
+			s, ok := gs.sightings[username][loc]
+			if !ok {
+				continue
+			}
+			ranks := []string{}
+			for _, u := range s.units {
+				ranks = append(ranks, string(u.Rank))
+			}
+			fmt.Fprintf(gs.Output(), "* %s: %d unit(s) in %s (%s), seen %s ago\n", username, len(s.units), loc, strings.Join(ranks, ", "), formatRemaining(now.Sub(s.at)))
+		}
+	}
+}
+
+// KnownEnemies counts the last known enemy units in each location. Allies are
+// left out.
+func (gs *GameState) KnownEnemies() map[Location]int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	enemies := map[Location]int{}
+	for username, seen := range gs.sightings {
+		if gs.allies[username] {
+			continue
+		}
+		for loc, s := range seen {
+			enemies[loc] += len(s.units)
+		}
+	}
+	return enemies
+}
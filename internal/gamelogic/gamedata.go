@@ -20,6 +20,7 @@ type Unit struct {
 }
 
 type ArmyMove struct {
+	GameID     string
 	Player     Player
 	Units      []Unit
 	ToLocation Location
@@ -28,6 +29,8 @@ type ArmyMove struct {
 type RecognitionOfWar struct {
 	Attacker Player
 	Defender Player
+	// Allies are the defender's allies as the server last saw them.
+	Allies []Player
 }
 
 type Location string
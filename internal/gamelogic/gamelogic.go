@@ -4,26 +4,17 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
-)
+	"syscall"
 
-func PrintClientHelp() {
-	fmt.Println("Possible commands:")
-	fmt.Println("* move <location> <unitID> <unitID> <unitID>...")
-	fmt.Println("    example:")
-	fmt.Println("    move asia 1")
-	fmt.Println("* spawn <location> <rank>")
-	fmt.Println("    example:")
-	fmt.Println("    spawn europe infantry")
-	fmt.Println("* status")
-	fmt.Println("* spam <n>")
-	fmt.Println("    example:")
-	fmt.Println("    spam 5")
-	fmt.Println("* quit")
-	fmt.Println("* help")
-}
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lineedit"
+)
 
 func ClientWelcome() (string, error) {
 	fmt.Println("Welcome to the Peril client!")
@@ -34,23 +25,101 @@ func ClientWelcome() (string, error) {
 	}
 	username := words[0]
 	fmt.Printf("Welcome, %s!\n", username)
-	PrintClientHelp()
 	return username, nil
 }
 
-func PrintServerHelp() {
-	fmt.Println("Possible commands:")
-	fmt.Println("* pause")
-	fmt.Println("* resume")
-	fmt.Println("* quit")
-	fmt.Println("* help")
+// stdin is shared by every GetInput call so lines buffered by one read are
+// not lost to the next, which matters when input is piped in.
+var (
+	stdin       = bufio.NewScanner(os.Stdin)
+	stdinClosed bool
+	editor      *lineedit.Editor
+)
+
+const historyDir = "history"
+
+// StartLineEditing makes GetInput use a line editor with history and tab
+// completion. It does nothing unless stdin is a terminal, so piped input
+// keeps working as before.
+func StartLineEditing() error {
+	if !lineedit.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	e := lineedit.New("> ")
+	if err := e.CaptureStdout(); err != nil {
+		return err
+	}
+	log.SetOutput(e)
+	editor = e
+	go restoreOnSignal()
+	return nil
+}
+
+// restoreOnSignal puts the terminal back before an interrupt or termination
+// signal ends the process, then lets the signal do what it would have done.
+func restoreOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	StopLineEditing()
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(sig)
+	}
+}
+
+// StopLineEditing restores the terminal. Call it before exiting from
+// anywhere other than the end of main.
+func StopLineEditing() {
+	if editor == nil {
+		return
+	}
+	log.SetOutput(os.Stderr)
+	editor.Close()
+	editor = nil
+}
+
+// LineEditor returns the editor GetInput reads from, or nil when stdin is not
+// a terminal.
+func LineEditor() *lineedit.Editor {
+	return editor
+}
+
+// LoadHistory switches to the command history kept for name.
+func LoadHistory(name string) error {
+	if editor == nil {
+		return nil
+	}
+	return editor.LoadHistory(filepath.Join(historyDir, name))
+}
+
+func SetCompleter(complete lineedit.Completer) {
+	if editor == nil {
+		return
+	}
+	editor.SetCompleter(complete)
+}
+
+// InputClosed reports whether GetInput has reached the end of stdin.
+func InputClosed() bool {
+	return stdinClosed
 }
 
 func GetInput() []string {
+	if editor != nil {
+		// ctrl-c on an empty line ends input just like ctrl-d
+		line, err := editor.ReadLine()
+		if err != nil {
+			stdinClosed = true
+			return nil
+		}
+		return strings.Fields(line)
+	}
 	fmt.Print("> ")
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := stdin
 	scanned := scanner.Scan()
 	if !scanned {
+		stdinClosed = true
 		return nil
 	}
 	line := scanner.Text()
@@ -77,16 +146,53 @@ func PrintQuit() {
 }
 
 func (gs *GameState) CommandStatus() {
-	if gs.isPaused() {
-		fmt.Println("The game is paused.")
+	if gs.IsOver() {
+		fmt.Fprintf(gs.Output(), "The game is over, %s won.\n", gs.GetWinner())
+	}
+	if gs.IsPaused() {
+		reason, remaining := gs.pauseInfo()
+		fmt.Fprintln(gs.Output(), "The game is paused.")
+		if reason != "" {
+			fmt.Fprintf(gs.Output(), "Reason: %s\n", reason)
+		}
+		if remaining > 0 {
+			fmt.Fprintf(gs.Output(), "The game resumes in %s.\n", formatRemaining(remaining))
+		} else {
+			fmt.Fprintln(gs.Output(), "The game is paused until the server resumes it.")
+		}
 		return
 	} else {
-		fmt.Println("The game is not paused.")
+		fmt.Fprintln(gs.Output(), "The game is not paused.")
+	}
+
+	if gs.InTurnMode() {
+		turn, open, remaining, orders, limit := gs.turnStatus()
+		if open {
+			fmt.Fprintf(gs.Output(), "Turn %d ends in %s. You have given %d of %d order(s).\n", turn, formatRemaining(remaining), orders, limit)
+		} else {
+			fmt.Fprintln(gs.Output(), "Waiting for the next turn to start.")
+		}
 	}
 
 	p := gs.GetPlayerSnap()
-	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Fprintf(gs.Output(), "You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Fprintf(gs.Output(), "You have %d resource(s).\n", gs.GetResources())
+	if allies := gs.getAllies(); len(allies) > 0 {
+		fmt.Fprintf(gs.Output(), "You are allied with %s.\n", strings.Join(allies, ", "))
+	}
+	standings := gs.getStandings()
+	names := []string{}
+	for username := range standings {
+		if username != p.Username {
+			names = append(names, username)
+		}
+	}
+	sort.Strings(names)
+	for _, username := range names {
+		fmt.Fprintf(gs.Output(), "  %s has %d resource(s)\n", username, standings[username])
+	}
 	for _, unit := range p.Units {
-		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		fmt.Fprintf(gs.Output(), "* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
 	}
+	gs.printSightings()
 }
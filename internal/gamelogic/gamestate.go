@@ -1,13 +1,34 @@
 package gamelogic
 
 import (
+	"io"
+	"os"
 	"sync"
+	"time"
 )
 
 type GameState struct {
-	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	Player      Player
+	GameID      string
+	Paused      bool
+	pauseReason string
+	pausedUntil time.Time
+	nextID      int
+	turns       turnState
+	ruleset     Ruleset
+	resources   int
+	standings   map[string]int
+	over        bool
+	winner      string
+	allies      map[string]bool
+	proposedTo  map[string]bool
+	proposedBy  map[string]bool
+	known       map[string]Player
+	sightings   map[string]map[Location]sighting
+	seq         int
+	journal     Journal
+	output      io.Writer
+	mu          *sync.RWMutex
 }
 
 func NewGameState(username string) *GameState {
@@ -16,55 +37,117 @@ func NewGameState(username string) *GameState {
 			Username: username,
 			Units:    map[int]Unit{},
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Paused:     false,
+		nextID:     1,
+		ruleset:    DefaultRuleset(),
+		resources:  DefaultRuleset().StartingResources,
+		standings:  map[string]int{},
+		allies:     map[string]bool{},
+		proposedTo: map[string]bool{},
+		proposedBy: map[string]bool{},
+		known:      map[string]Player{},
+		sightings:  map[string]map[Location]sighting{},
+		mu:         &sync.RWMutex{},
 	}
 }
 
+// SetOutput sends everything the game state prints to w instead of
+// os.Stdout. Call it before the game state is shared.
+func (gs *GameState) SetOutput(w io.Writer) {
+	gs.output = w
+}
+
+// Output is where the game state prints, for whoever prints on its behalf.
+func (gs *GameState) Output() io.Writer {
+	if gs.output == nil {
+		return os.Stdout
+	}
+	return gs.output
+}
+
 func (gs *GameState) resumeGame() {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Paused = false
+	gs.record(Event{Type: EventGamePaused, Paused: false})
 }
 
-func (gs *GameState) pauseGame() {
+func (gs *GameState) pauseGame(reason string, until time.Time) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Paused = true
+	e := Event{Type: EventGamePaused, Paused: true, Reason: reason}
+	if !until.IsZero() {
+		e.Until = &until
+	}
+	gs.record(e)
+}
+
+// IsPaused treats a timed pause as over once its deadline passes, even if
+// the server's resume message hasn't arrived yet.
+func (gs *GameState) IsPaused() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.pausedNow(time.Now())
 }
 
-func (gs *GameState) isPaused() bool {
+// pausedNow must be called with gs.mu held.
+func (gs *GameState) pausedNow(now time.Time) bool {
+	if !gs.Paused {
+		return false
+	}
+	return gs.pausedUntil.IsZero() || now.Before(gs.pausedUntil)
+}
+
+// pauseInfo returns the pause reason and the time left, which is zero for an
+// indefinite pause.
+func (gs *GameState) pauseInfo() (string, time.Duration) {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
-	return gs.Paused
+	if gs.pausedUntil.IsZero() {
+		return gs.pauseReason, 0
+	}
+	return gs.pauseReason, time.Until(gs.pausedUntil)
 }
 
 func (gs *GameState) addUnit(u Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	gs.record(Event{Type: EventUnitSpawned, Unit: &u})
+}
+
+func (gs *GameState) nextUnitID() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.nextID
 }
 
 func (gs *GameState) removeUnitsInLocation(loc Location) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
-		}
-	}
+	gs.record(Event{Type: EventUnitsDestroyed, Location: loc})
 }
 
 func (gs *GameState) UpdateUnit(u Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	gs.record(Event{Type: EventUnitMoved, Unit: &u})
 }
 
 func (gs *GameState) GetUsername() string {
 	return gs.Player.Username
 }
 
+func (gs *GameState) SetGameID(gameID string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.GameID = gameID
+}
+
+func (gs *GameState) GetGameID() string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.GameID
+}
+
 func (gs *GameState) getUnitsSnap() []Unit {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
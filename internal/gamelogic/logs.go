@@ -1,9 +1,12 @@
 package gamelogic
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
@@ -13,20 +16,84 @@ const logsFile = "game.log"
 
 const writeToDiskSleep = 1 * time.Second
 
+// WriteLog appends gamelog to the log file as one JSON object per line.
 func WriteLog(gamelog routing.GameLog) error {
 	log.Printf("received game log...")
 	time.Sleep(writeToDiskSleep)
 
+	gamelog.Normalize()
+	line, err := json.Marshal(gamelog)
+	if err != nil {
+		return fmt.Errorf("could not encode game log: %v", err)
+	}
+
 	f, err := os.OpenFile(logsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("could not open logs file: %v", err)
 	}
 	defer f.Close()
 
-	str := fmt.Sprintf("%v %v: %v\n", gamelog.CurrentTime.Format(time.RFC3339), gamelog.Username, gamelog.Message)
-	_, err = f.WriteString(str)
+	_, err = f.Write(append(line, '\n'))
 	if err != nil {
 		return fmt.Errorf("could not write to logs file: %v", err)
 	}
 	return nil
 }
+
+// ReadLogs reads a log file written by WriteLog. Lines in the older text
+// format are read too, as untyped messages.
+func ReadLogs(path string) ([]routing.GameLog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs file: %v", err)
+	}
+	defer f.Close()
+
+	logs := []routing.GameLog{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		gamelog, ok := parseLogLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		gamelog.Normalize()
+		logs = append(logs, gamelog)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read logs file: %v", err)
+	}
+	return logs, nil
+}
+
+func parseLogLine(line string) (routing.GameLog, bool) {
+	if strings.HasPrefix(line, "{") {
+		var gamelog routing.GameLog
+		if err := json.Unmarshal([]byte(line), &gamelog); err != nil {
+			return routing.GameLog{}, false
+		}
+		return gamelog, true
+	}
+	return parseTextLogLine(line)
+}
+
+// parseTextLogLine reverses the "<time> <username>: <message>" format older
+// servers wrote.
+func parseTextLogLine(line string) (routing.GameLog, bool) {
+	ts, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return routing.GameLog{}, false
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return routing.GameLog{}, false
+	}
+	username, message, ok := strings.Cut(rest, ": ")
+	if !ok {
+		return routing.GameLog{}, false
+	}
+	return routing.GameLog{
+		CurrentTime: t,
+		Username:    username,
+		Message:     message,
+	}, true
+}
@@ -3,7 +3,7 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"time"
 )
 
 type MoveOutcome int
@@ -15,26 +15,36 @@ const (
 )
 
 func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
-	defer fmt.Println("------------------------")
+	// moves still in flight when the game ended change nothing
+	if gs.IsOver() {
+		return MoveOutComeSafe
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
 	player := gs.GetPlayerSnap()
 
-	fmt.Println()
-	fmt.Println("==== Move Detected ====")
-	fmt.Printf("%s is moving %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
+	fmt.Fprintln(gs.Output())
+	fmt.Fprintln(gs.Output(), "==== Move Detected ====")
+	fmt.Fprintf(gs.Output(), "%s is moving %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
 	for _, unit := range move.Units {
-		fmt.Printf("* %v\n", unit.Rank)
+		fmt.Fprintf(gs.Output(), "* %v\n", unit.Rank)
 	}
 
 	if player.Username == move.Player.Username {
 		return MoveOutcomeSamePlayer
 	}
+	gs.remember(move.Player)
+	gs.sight(move.Player, time.Now())
+	if gs.isAlly(move.Player.Username) {
+		fmt.Fprintf(gs.Output(), "%s is your ally, your units can share locations.\n", move.Player.Username)
+		return MoveOutComeSafe
+	}
 
 	overlappingLocation := getOverlappingLocation(player, move.Player)
 	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
+		fmt.Fprintf(gs.Output(), "You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
 		return MoveOutcomeMakeWar
 	}
-	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
+	fmt.Fprintf(gs.Output(), "You are safe from %s's units.\n", move.Player.Username)
 	return MoveOutComeSafe
 }
 
@@ -49,44 +59,58 @@ func getOverlappingLocation(p1 Player, p2 Player) Location {
 	return ""
 }
 
-func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
-	if gs.isPaused() {
-		return ArmyMove{}, errors.New("the game is paused, you can not move units")
+func (gs *GameState) CommandMove(newLocation Location, unitIDs []int) (ArmyMove, error) {
+	if gs.IsOver() {
+		return ArmyMove{}, errGameOver
 	}
-	if len(words) < 3 {
-		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
-	}
-	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
-		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
-	}
-	unitIDs := []int{}
-	for _, word := range words[2:] {
-		id := word
-		unitID, err := strconv.Atoi(id)
-		if err != nil {
-			return ArmyMove{}, fmt.Errorf("error: %s is not a valid unit ID", id)
-		}
-		unitIDs = append(unitIDs, unitID)
+	if gs.IsPaused() {
+		return ArmyMove{}, errors.New("the game is paused, you can not move units")
 	}
 
 	newUnits := []Unit{}
 	for _, unitID := range unitIDs {
 		unit, ok := gs.GetUnit(unitID)
 		if !ok {
-			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
+			return ArmyMove{}, fmt.Errorf("unit with ID %v not found", unitID)
 		}
 		unit.Location = newLocation
-		gs.UpdateUnit(unit)
 		newUnits = append(newUnits, unit)
 	}
 
+	if gs.InTurnMode() {
+		return gs.orderMove(newLocation, newUnits)
+	}
+
+	for _, unit := range newUnits {
+		gs.UpdateUnit(unit)
+	}
+
 	mv := ArmyMove{
+		GameID:     gs.GetGameID(),
 		ToLocation: newLocation,
 		Units:      newUnits,
 		Player:     gs.GetPlayerSnap(),
 	}
-	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
+	fmt.Fprintf(gs.Output(), "Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
+	return mv, nil
+}
+
+// orderMove queues a move for the end of the turn instead of carrying it out.
+// The player snapshot already shows the ordered units in their destination.
+// The order only counts once the server accepts it.
+func (gs *GameState) orderMove(to Location, units []Unit) (ArmyMove, error) {
+	if err := gs.takeOrder(); err != nil {
+		return ArmyMove{}, err
+	}
+	player := gs.GetPlayerSnap()
+	for _, unit := range units {
+		player.Units[unit.ID] = unit
+	}
+	mv := ArmyMove{
+		GameID:     gs.GetGameID(),
+		ToLocation: to,
+		Units:      units,
+		Player:     player,
+	}
 	return mv, nil
 }
@@ -2,18 +2,59 @@ package gamelogic
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 )
 
 func (gs *GameState) HandlePause(ps routing.PlayingState) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
+	if ps.GameID != "" && ps.GameID != gs.GetGameID() {
+		return
+	}
+	if ps.Target != "" && ps.Target != gs.GetUsername() {
+		return
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	if len(ps.Roster) > 0 {
+		fmt.Fprintln(gs.Output(), "==== Game Started ====")
+		fmt.Fprintf(gs.Output(), "Game %s has started with %d player(s):\n", ps.GameID, len(ps.Roster))
+		for _, username := range ps.Roster {
+			fmt.Fprintf(gs.Output(), "* %s\n", username)
+		}
+		// the server funds every player with the same starting balance
+		gs.mu.Lock()
+		gs.setBalance(gs.ruleset.StartingResources, "starting balance")
+		gs.mu.Unlock()
+		if ps.TurnMode {
+			gs.EnableTurns(ps.OrderLimit)
+			fmt.Fprintf(gs.Output(), "This is a turn-based game: %s per turn, up to %d order(s) each.\n", ps.TurnLength, ps.OrderLimit)
+		}
+	}
 	if ps.IsPaused {
-		fmt.Println("==== Pause Detected ====")
-		gs.pauseGame()
+		fmt.Fprintln(gs.Output(), "==== Pause Detected ====")
+		if ps.Target != "" {
+			fmt.Fprintln(gs.Output(), "Only you have been paused.")
+		}
+		if ps.Reason != "" {
+			fmt.Fprintf(gs.Output(), "Reason: %s\n", ps.Reason)
+		}
+		if !ps.ResumeAt.IsZero() {
+			fmt.Fprintf(gs.Output(), "The game resumes in %s.\n", formatRemaining(time.Until(ps.ResumeAt)))
+		}
+		gs.pauseGame(ps.Reason, ps.ResumeAt)
 	} else {
-		fmt.Println("==== Resume Detected ====")
+		fmt.Fprintln(gs.Output(), "==== Resume Detected ====")
+		if ps.Reason != "" {
+			fmt.Fprintf(gs.Output(), "Reason: %s\n", ps.Reason)
+		}
 		gs.resumeGame()
 	}
 }
+
+func formatRemaining(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,26 @@
+package gamelogic
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func (gs *GameState) HandlePresence(pe routing.PresenceEvent) {
+	if pe.Username == gs.GetUsername() {
+		return
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	where := "the lobby"
+	if pe.GameID != "" {
+		where = "game " + pe.GameID
+	}
+	if pe.Online {
+		fmt.Fprintln(gs.Output(), "==== Player Joined ====")
+		fmt.Fprintf(gs.Output(), "%s is online in %s.\n", pe.Username, where)
+	} else {
+		fmt.Fprintln(gs.Output(), "==== Player Left ====")
+		fmt.Fprintf(gs.Output(), "%s has left %s.\n", pe.Username, where)
+	}
+}
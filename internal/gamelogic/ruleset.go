@@ -0,0 +1,62 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ruleset holds the tunable numbers of a game of Peril.
+type Ruleset struct {
+	RankCosts         map[UnitRank]int
+	StartingResources int
+	IncomePerLocation int
+	IncomeInterval    time.Duration
+}
+
+func DefaultRuleset() Ruleset {
+	return Ruleset{
+		RankCosts: map[UnitRank]int{
+			RankInfantry:  1,
+			RankCavalry:   4,
+			RankArtillery: 9,
+		},
+		StartingResources: 10,
+		IncomePerLocation: 1,
+		IncomeInterval:    10 * time.Second,
+	}
+}
+
+func (r Ruleset) Cost(rank UnitRank) int {
+	return r.RankCosts[rank]
+}
+
+// Income is what a player earns per tick for the locations their units hold.
+func (r Ruleset) Income(p Player) int {
+	held := map[Location]struct{}{}
+	for _, u := range p.Units {
+		held[u.Location] = struct{}{}
+	}
+	return len(held) * r.IncomePerLocation
+}
+
+// DescribeCosts lists what each rank costs, cheapest first.
+func (r Ruleset) DescribeCosts() string {
+	ranks := []UnitRank{}
+	for rank := range r.RankCosts {
+		ranks = append(ranks, rank)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		return r.Cost(ranks[i]) < r.Cost(ranks[j])
+	})
+	parts := []string{}
+	for _, rank := range ranks {
+		parts = append(parts, fmt.Sprintf("%s %d", rank, r.Cost(rank)))
+	}
+	return "costs: " + strings.Join(parts, ", ")
+}
+
+func (gs *GameState) Ruleset() Ruleset {
+	return gs.ruleset
+}
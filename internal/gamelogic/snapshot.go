@@ -0,0 +1,243 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const savesDir = "saves"
+
+const autosaveName = "autosave"
+
+// SnapshotVersion is the version written by SaveSnapshot. Bump it and add an
+// entry to snapshotMigrations whenever the Snapshot layout changes.
+const SnapshotVersion = 3
+
+type Snapshot struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"saved_at"`
+	Player  Player    `json:"player"`
+	Paused  bool      `json:"paused"`
+	NextID  int       `json:"next_id"`
+
+	// saves without a pause reason or deadline read back as an indefinite
+	// pause, so adding them needed no migration
+	PauseReason string    `json:"pause_reason"`
+	PausedUntil time.Time `json:"paused_until"`
+	Resources   int       `json:"resources"`
+}
+
+// snapshotMigrations upgrade a raw snapshot from the keyed version to the
+// next one.
+var snapshotMigrations = map[int]func(map[string]json.RawMessage) error{
+	1: migrateSnapshotV1,
+	2: migrateSnapshotV2,
+}
+
+// v1 -> v2: the unit ID counter is stored instead of being derived from the
+// number of units, so IDs are never reused after units are killed.
+func migrateSnapshotV1(raw map[string]json.RawMessage) error {
+	if _, ok := raw["next_id"]; ok {
+		return nil
+	}
+	var player Player
+	if v, ok := raw["player"]; ok {
+		if err := json.Unmarshal(v, &player); err != nil {
+			return fmt.Errorf("could not read player: %v", err)
+		}
+	}
+	nextID := 1
+	for id := range player.Units {
+		if id >= nextID {
+			nextID = id + 1
+		}
+	}
+	b, err := json.Marshal(nextID)
+	if err != nil {
+		return err
+	}
+	raw["next_id"] = b
+	return nil
+}
+
+// v2 -> v3: spawning costs resources. Saves from before the economy start
+// with the default starting balance.
+func migrateSnapshotV2(raw map[string]json.RawMessage) error {
+	if _, ok := raw["resources"]; ok {
+		return nil
+	}
+	b, err := json.Marshal(DefaultRuleset().StartingResources)
+	if err != nil {
+		return err
+	}
+	raw["resources"] = b
+	return nil
+}
+
+func (gs *GameState) Snapshot() Snapshot {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	units := map[int]Unit{}
+	for k, v := range gs.Player.Units {
+		units[k] = v
+	}
+	return Snapshot{
+		Version: SnapshotVersion,
+		SavedAt: time.Now(),
+		Player: Player{
+			Username: gs.Player.Username,
+			Units:    units,
+		},
+		Paused:      gs.Paused,
+		NextID:      gs.nextID,
+		PauseReason: gs.pauseReason,
+		PausedUntil: gs.pausedUntil,
+		Resources:   gs.resources,
+	}
+}
+
+func (gs *GameState) Restore(s Snapshot) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.record(Event{Type: EventStateRestored, Snapshot: &s})
+}
+
+func DecodeSnapshot(data []byte) (Snapshot, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Snapshot{}, fmt.Errorf("could not decode snapshot: %v", err)
+	}
+
+	var version int
+	v, ok := raw["version"]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("snapshot has no version")
+	}
+	if err := json.Unmarshal(v, &version); err != nil {
+		return Snapshot{}, fmt.Errorf("could not decode snapshot version: %v", err)
+	}
+	if version < 1 {
+		return Snapshot{}, fmt.Errorf("snapshot version %d is not supported", version)
+	}
+	if version > SnapshotVersion {
+		return Snapshot{}, fmt.Errorf("snapshot version %d is newer than supported version %d", version, SnapshotVersion)
+	}
+	for ; version < SnapshotVersion; version++ {
+		migrate, ok := snapshotMigrations[version]
+		if !ok {
+			return Snapshot{}, fmt.Errorf("no migration from snapshot version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return Snapshot{}, fmt.Errorf("could not migrate snapshot from version %d: %v", version, err)
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s := Snapshot{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Snapshot{}, fmt.Errorf("could not decode snapshot: %v", err)
+	}
+	s.Version = SnapshotVersion
+	if s.Player.Units == nil {
+		s.Player.Units = map[int]Unit{}
+	}
+	return s, nil
+}
+
+func snapshotPath(username, name string) (string, error) {
+	if err := routing.ValidateUsername(username); err != nil {
+		return "", fmt.Errorf("error: %v", err)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("error: %s is not a valid save name", name)
+	}
+	return filepath.Join(savesDir, username, name+".json"), nil
+}
+
+func (gs *GameState) SaveSnapshot(name string) (string, error) {
+	path, err := snapshotPath(gs.GetUsername(), name)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(gs.Snapshot(), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode snapshot: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("could not create saves directory: %v", err)
+	}
+	// write to a temp file first so a crash never leaves a half-written save
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("could not write snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("could not write snapshot: %v", err)
+	}
+	return path, nil
+}
+
+func (gs *GameState) LoadSnapshot(name string) (string, error) {
+	path, err := snapshotPath(gs.GetUsername(), name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("error: no save named %s", name)
+		}
+		return "", fmt.Errorf("could not read snapshot: %v", err)
+	}
+	s, err := DecodeSnapshot(data)
+	if err != nil {
+		return "", err
+	}
+	if s.Player.Username != "" && s.Player.Username != gs.GetUsername() {
+		return "", fmt.Errorf("error: save %s belongs to %s", name, s.Player.Username)
+	}
+	gs.Restore(s)
+	return path, nil
+}
+
+func (gs *GameState) CommandSave(name string) error {
+	path, err := gs.SaveSnapshot(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(gs.Output(), "Saved game to %s\n", path)
+	return nil
+}
+
+// CommandLoad only works outside a game: the server keeps the positions and
+// balances of a game in progress, so restoring old ones would desync them.
+func (gs *GameState) CommandLoad(name string) error {
+	if gs.GetGameID() != "" && !gs.IsOver() {
+		return errors.New("you can not load a save during a game")
+	}
+	path, err := gs.LoadSnapshot(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(gs.Output(), "Loaded game from %s\n", path)
+	gs.CommandStatus()
+	return nil
+}
+
+func (gs *GameState) Autosave() (string, error) {
+	return gs.SaveSnapshot(autosaveName)
+}
+
+func (gs *GameState) Resume() (string, error) {
+	return gs.LoadSnapshot(autosaveName)
+}
@@ -0,0 +1,116 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeSnapshotMigrates(t *testing.T) {
+	units := map[int]Unit{
+		1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+		4: {ID: 4, Rank: RankCavalry, Location: "asia"},
+	}
+	tests := []struct {
+		name string
+		data string
+		want Snapshot
+	}{
+		{
+			name: "v1 derives the next unit ID and gets the starting balance",
+			data: `{"version":1,"saved_at":"2024-05-01T12:00:00Z","player":{"Username":"alice","Units":{
+				"1":{"ID":1,"Rank":"infantry","Location":"europe"},
+				"4":{"ID":4,"Rank":"cavalry","Location":"asia"}}},"paused":true}`,
+			want: Snapshot{
+				Player:    Player{Username: "alice", Units: units},
+				Paused:    true,
+				NextID:    5,
+				Resources: DefaultRuleset().StartingResources,
+			},
+		},
+		{
+			name: "v1 without units starts IDs at 1",
+			data: `{"version":1,"player":{"Username":"alice"}}`,
+			want: Snapshot{
+				Player:    Player{Username: "alice", Units: map[int]Unit{}},
+				NextID:    1,
+				Resources: DefaultRuleset().StartingResources,
+			},
+		},
+		{
+			name: "v2 keeps its next unit ID and gets the starting balance",
+			data: `{"version":2,"player":{"Username":"alice","Units":{
+				"1":{"ID":1,"Rank":"infantry","Location":"europe"},
+				"4":{"ID":4,"Rank":"cavalry","Location":"asia"}}},"next_id":9,
+				"pause_reason":"lunch","paused":true}`,
+			want: Snapshot{
+				Player:      Player{Username: "alice", Units: units},
+				Paused:      true,
+				PauseReason: "lunch",
+				NextID:      9,
+				Resources:   DefaultRuleset().StartingResources,
+			},
+		},
+		{
+			name: "v3 is read as is",
+			data: `{"version":3,"player":{"Username":"alice","Units":{}},"next_id":2,"resources":3}`,
+			want: Snapshot{
+				Player:    Player{Username: "alice", Units: map[int]Unit{}},
+				NextID:    2,
+				Resources: 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := DecodeSnapshot([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DecodeSnapshot: %v", err)
+			}
+			if s.Version != SnapshotVersion {
+				t.Errorf("version = %d, want %d", s.Version, SnapshotVersion)
+			}
+			s.Version, s.SavedAt = 0, time.Time{}
+			if !reflect.DeepEqual(s, tt.want) {
+				t.Errorf("snapshot = %+v\nwant       %+v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSnapshotRejects(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"player":{}}`,
+		`{"version":0}`,
+		`{"version":99}`,
+		`{"version":1,"player":"alice"}`,
+	} {
+		if _, err := DecodeSnapshot([]byte(data)); err == nil {
+			t.Errorf("DecodeSnapshot(%s) succeeded", data)
+		}
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	gs := NewGameState("alice")
+	gs.addUnit(Unit{ID: 1, Rank: RankArtillery, Location: "africa"})
+	gs.pauseGame("lunch", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	data, err := json.MarshalIndent(gs.Snapshot(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := DecodeSnapshot(data)
+	if err != nil {
+		t.Fatalf("DecodeSnapshot: %v", err)
+	}
+	restored := NewGameState("alice")
+	restored.Restore(s)
+	got, want := restored.Snapshot(), gs.Snapshot()
+	got.SavedAt, want.SavedAt = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored snapshot = %+v\nwant                %+v", got, want)
+	}
+}
@@ -5,30 +5,42 @@ import (
 	"fmt"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
-	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+// CheckSpawn fails fast on a spawn the server would refuse anyway.
+func (gs *GameState) CheckSpawn(location Location, rank UnitRank) error {
+	if gs.IsOver() {
+		return errGameOver
 	}
-
-	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
+	if gs.IsPaused() {
+		return errors.New("the game is paused, you can not spawn units")
 	}
-
-	rank := words[2]
-	units := getAllRanks()
-	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
+	cost, ok := gs.ruleset.RankCosts[rank]
+	if !ok {
+		return fmt.Errorf("%s is not a unit rank", rank)
 	}
+	if balance := gs.GetResources(); cost > balance {
+		return fmt.Errorf("insufficient funds: a(n) %s costs %d but you have %d", rank, cost, balance)
+	}
+	return nil
+}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
-		Rank:     UnitRank(rank),
-		Location: Location(locationName),
-	})
+// CommandSpawn adds a unit the server has already charged for. balance is
+// what the server says the player has left.
+func (gs *GameState) CommandSpawn(location Location, rank UnitRank, balance int) {
+	id := gs.buyUnit(rank, location, balance)
+	fmt.Fprintf(gs.Output(), "Spawned a(n) %s in %s with id %v for %d resource(s)\n", rank, location, id, gs.ruleset.Cost(rank))
+}
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return nil
+// buyUnit pays for and adds a unit in one step so a spawn is never free or
+// lost halfway.
+func (gs *GameState) buyUnit(rank UnitRank, loc Location, balance int) int {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.setBalance(balance, fmt.Sprintf("a(n) %s", rank))
+	u := Unit{
+		ID:       gs.nextID,
+		Rank:     rank,
+		Location: loc,
+	}
+	gs.record(Event{Type: EventUnitSpawned, Unit: &u})
+	return u.ID
 }
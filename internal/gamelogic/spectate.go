@@ -0,0 +1,111 @@
+package gamelogic
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// SpectateRequest asks the server to watch a started game. Only names
+// registered as spectators may watch.
+type SpectateRequest struct {
+	Username string
+	Token    string
+	GameID   string
+}
+
+// SpectateResponse carries every army the server knows of in the game, so a
+// spectator who arrives late sees the whole board at once.
+type SpectateResponse struct {
+	Game   routing.GameSummary
+	Armies []Player
+	Error  string
+}
+
+// CommandWatchStatus is the status of a game being watched rather than
+// played.
+func (gs *GameState) CommandWatchStatus() {
+	fmt.Fprintf(gs.Output(), "You are watching game %s.\n", gs.GetGameID())
+	if gs.IsOver() {
+		fmt.Fprintf(gs.Output(), "The game is over, %s won.\n", gs.GetWinner())
+		return
+	}
+	if !gs.IsPaused() {
+		fmt.Fprintln(gs.Output(), "The game is not paused.")
+		return
+	}
+	reason, remaining := gs.pauseInfo()
+	fmt.Fprintln(gs.Output(), "The game is paused.")
+	if reason != "" {
+		fmt.Fprintf(gs.Output(), "Reason: %s\n", reason)
+	}
+	if remaining > 0 {
+		fmt.Fprintf(gs.Output(), "The game resumes in %s.\n", formatRemaining(remaining))
+	}
+}
+
+// PrintWorld shows every known army by location, with the owner of each
+// location that only one player holds.
+func PrintWorld(out io.Writer, players []Player) {
+	if len(players) == 0 {
+		fmt.Fprintln(out, "No armies have been seen yet.")
+		return
+	}
+	owners := Territory(players)
+	for _, loc := range Locations() {
+		armies := []string{}
+		for _, p := range players {
+			ranks := map[UnitRank]int{}
+			for _, u := range p.Units {
+				if u.Location == loc {
+					ranks[u.Rank]++
+				}
+			}
+			if len(ranks) == 0 {
+				continue
+			}
+			armies = append(armies, fmt.Sprintf("%s (%s)", p.Username, describeRanks(ranks)))
+		}
+		switch {
+		case len(armies) == 0:
+			fmt.Fprintf(out, "* %s: empty\n", loc)
+		case owners[loc] != "":
+			fmt.Fprintf(out, "* %s: held by %s\n", loc, armies[0])
+		default:
+			fmt.Fprintf(out, "* %s: contested by %s\n", loc, strings.Join(armies, ", "))
+		}
+	}
+}
+
+// PrintArmies lists each known player's units.
+func PrintArmies(out io.Writer, players []Player) {
+	if len(players) == 0 {
+		fmt.Fprintln(out, "No armies have been seen yet.")
+		return
+	}
+	for _, p := range players {
+		fmt.Fprintf(out, "%s has %d unit(s):\n", p.Username, len(p.Units))
+		ids := []int{}
+		for id := range p.Units {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			unit := p.Units[id]
+			fmt.Fprintf(out, "  * %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		}
+	}
+}
+
+func describeRanks(ranks map[UnitRank]int) string {
+	parts := []string{}
+	for _, rank := range []UnitRank{RankInfantry, RankCavalry, RankArtillery} {
+		if n := ranks[rank]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, rank))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
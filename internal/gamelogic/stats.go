@@ -0,0 +1,111 @@
+package gamelogic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WarResult is how a war ended. The player who fought it publishes it so the
+// server can keep everyone's statistics.
+type WarResult struct {
+	GameID   string
+	Attacker string
+	Defender string
+	// Winner is empty when the war was a draw.
+	Winner         string
+	Location       Location
+	AttackerLosses int
+	DefenderLosses int
+	At             time.Time
+}
+
+// WarResultReport carries a war's result from the session of the attacker
+// who fought it.
+type WarResultReport struct {
+	Username string
+	Token    string
+	Result   WarResult
+}
+
+// Result describes the war's outcome: the loser loses every unit they had
+// where the war was fought, and a draw costs both sides theirs.
+func (rw RecognitionOfWar) Result(gameID, winner string) WarResult {
+	loc := rw.Location()
+	r := WarResult{
+		GameID:   gameID,
+		Attacker: rw.Attacker.Username,
+		Defender: rw.Defender.Username,
+		Winner:   winner,
+		Location: loc,
+		At:       time.Now(),
+	}
+	if winner != rw.Attacker.Username {
+		r.AttackerLosses = unitsIn(rw.Attacker, loc)
+	}
+	if winner != rw.Defender.Username {
+		r.DefenderLosses = unitsIn(rw.Defender, loc)
+	}
+	return r
+}
+
+// Fields describes the war for a structured game log.
+func (r WarResult) Fields() map[string]string {
+	outcome := "win"
+	if r.Winner == "" {
+		outcome = "draw"
+	}
+	return map[string]string{
+		"outcome":         outcome,
+		"attacker":        r.Attacker,
+		"defender":        r.Defender,
+		"winner":          r.Winner,
+		"location":        string(r.Location),
+		"attacker_losses": strconv.Itoa(r.AttackerLosses),
+		"defender_losses": strconv.Itoa(r.DefenderLosses),
+	}
+}
+
+func unitsIn(p Player, loc Location) int {
+	n := 0
+	for _, u := range p.Units {
+		if u.Location == loc {
+			n++
+		}
+	}
+	return n
+}
+
+// PlayerStats is a player's war record across every game they fought in.
+type PlayerStats struct {
+	Username    string
+	Games       int
+	Wins        int
+	Losses      int
+	Draws       int
+	UnitsKilled int
+	UnitsLost   int
+}
+
+type LeaderboardRequest struct {
+	// Limit is how many players to return; zero means all.
+	Limit int
+}
+
+type LeaderboardResponse struct {
+	Players []PlayerStats
+	Error   string
+}
+
+func PrintLeaderboard(out io.Writer, players []PlayerStats) {
+	if len(players) == 0 {
+		fmt.Fprintln(out, "No wars have been fought yet.")
+		return
+	}
+	fmt.Fprintln(out, "==== Leaderboard ====")
+	for i, p := range players {
+		fmt.Fprintf(out, "%d. %s: %d win(s), %d loss(es), %d draw(s) in %d game(s); %d unit(s) killed, %d lost\n",
+			i+1, p.Username, p.Wins, p.Losses, p.Draws, p.Games, p.UnitsKilled, p.UnitsLost)
+	}
+}
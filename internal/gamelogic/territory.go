@@ -0,0 +1,103 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GameOver is broadcast by the server once a player has met a win condition.
+// Clients stop giving and resolving moves after receiving it.
+type GameOver struct {
+	GameID string
+	Winner string
+	// Allies are the winner's allies, who share the victory.
+	Allies    []string
+	Condition string
+	Reason    string
+	At        time.Time
+	Territory map[Location]string
+}
+
+var errGameOver = errors.New("the game is over")
+
+// Locations lists every location on the board in a stable order.
+func Locations() []Location {
+	locations := []Location{}
+	for loc := range getAllLocations() {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+// Territory maps each location to the only player with units in it.
+// Contested and empty locations have no owner.
+func Territory(players []Player) map[Location]string {
+	owners := map[Location]string{}
+	contested := map[Location]bool{}
+	for _, p := range players {
+		for _, u := range p.Units {
+			if contested[u.Location] {
+				continue
+			}
+			owner, ok := owners[u.Location]
+			if ok && owner != p.Username {
+				delete(owners, u.Location)
+				contested[u.Location] = true
+				continue
+			}
+			owners[u.Location] = p.Username
+		}
+	}
+	return owners
+}
+
+func (gs *GameState) HandleGameOver(over GameOver) {
+	if over.GameID != gs.GetGameID() {
+		return
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	fmt.Fprintln(gs.Output(), "==== Game Over ====")
+	switch {
+	case over.Winner == gs.GetUsername():
+		fmt.Fprintln(gs.Output(), "You won!")
+	case slices.Contains(over.Allies, gs.GetUsername()):
+		fmt.Fprintf(gs.Output(), "Your ally %s won, and you share the victory!\n", over.Winner)
+	default:
+		fmt.Fprintf(gs.Output(), "%s won.\n", over.Winner)
+	}
+	if len(over.Allies) > 0 {
+		fmt.Fprintf(gs.Output(), "Allied with the winner: %s\n", strings.Join(over.Allies, ", "))
+	}
+	if over.Reason != "" {
+		fmt.Fprintln(gs.Output(), over.Reason)
+	}
+	for _, loc := range Locations() {
+		if owner, ok := over.Territory[loc]; ok {
+			fmt.Fprintf(gs.Output(), "* %s held by %s\n", loc, owner)
+		}
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.record(Event{Type: EventGameOver, Winner: over.Winner, Reason: over.Reason})
+}
+
+func (gs *GameState) IsOver() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.over
+}
+
+func (gs *GameState) GetWinner() string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.winner
+}
@@ -0,0 +1,143 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TurnPhase string
+
+const (
+	TurnPhaseStart TurnPhase = "start"
+	TurnPhaseEnd   TurnPhase = "end"
+)
+
+// TurnTick is published by the server at the start and end of every turn.
+// The end tick carries every order submitted during the turn so all clients
+// resolve them at once.
+type TurnTick struct {
+	GameID     string
+	Turn       int
+	Phase      TurnPhase
+	Deadline   time.Time
+	OrderLimit int
+	Moves      []ArmyMove
+}
+
+// OrderRequest carries one order for the end of the turn from the session
+// that gave it.
+type OrderRequest struct {
+	Username string
+	Token    string
+	Move     ArmyMove
+}
+
+// OrderResponse tells the player whether the server took their order.
+type OrderResponse struct {
+	Error string
+}
+
+type turnState struct {
+	enabled  bool
+	turn     int
+	open     bool
+	deadline time.Time
+	orders   int
+	limit    int
+}
+
+// EnableTurns keeps whatever turn is already open, since the first turn's
+// tick can be handled before the game's start.
+func (gs *GameState) EnableTurns(orderLimit int) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.turns.enabled = true
+	gs.turns.limit = orderLimit
+}
+
+func (gs *GameState) InTurnMode() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turns.enabled
+}
+
+// takeOrder reserves one of this turn's orders.
+func (gs *GameState) takeOrder() error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if !gs.turns.open {
+		return errors.New("the turn is over, wait for the next one to give orders")
+	}
+	if gs.turns.limit > 0 && gs.turns.orders >= gs.turns.limit {
+		return fmt.Errorf("you have already given %d order(s) this turn", gs.turns.limit)
+	}
+	gs.turns.orders++
+	return nil
+}
+
+// ReleaseOrder gives back an order the server refused.
+func (gs *GameState) ReleaseOrder() {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if gs.turns.orders > 0 {
+		gs.turns.orders--
+	}
+}
+
+// HandleTurnTick opens or closes a turn. At the end of a turn the player's
+// own orders are carried out first and the other players' moves are returned
+// so they can be checked against the new positions.
+func (gs *GameState) HandleTurnTick(tick TurnTick) []ArmyMove {
+	if tick.GameID != gs.GetGameID() || gs.IsOver() {
+		return nil
+	}
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+
+	if tick.Phase == TurnPhaseStart {
+		gs.mu.Lock()
+		gs.turns.turn = tick.Turn
+		gs.turns.open = true
+		gs.turns.deadline = tick.Deadline
+		gs.turns.orders = 0
+		if tick.OrderLimit > 0 {
+			gs.turns.limit = tick.OrderLimit
+		}
+		limit := gs.turns.limit
+		gs.mu.Unlock()
+		fmt.Fprintf(gs.Output(), "==== Turn %d ====\n", tick.Turn)
+		fmt.Fprintf(gs.Output(), "Give up to %d order(s) in the next %s.\n", limit, formatRemaining(time.Until(tick.Deadline)))
+		return nil
+	}
+
+	gs.mu.Lock()
+	gs.turns.open = false
+	gs.mu.Unlock()
+	fmt.Fprintf(gs.Output(), "==== Turn %d Resolved ====\n", tick.Turn)
+	fmt.Fprintf(gs.Output(), "%d order(s) were given this turn.\n", len(tick.Moves))
+
+	username := gs.GetUsername()
+	others := []ArmyMove{}
+	for _, mv := range tick.Moves {
+		if mv.Player.Username != username {
+			others = append(others, mv)
+			continue
+		}
+		for _, unit := range mv.Units {
+			// the unit may have died in a war since the order was given
+			if _, ok := gs.GetUnit(unit.ID); !ok {
+				continue
+			}
+			gs.UpdateUnit(unit)
+		}
+		fmt.Fprintf(gs.Output(), "Your %d unit(s) arrived in %s.\n", len(mv.Units), mv.ToLocation)
+	}
+	return others
+}
+
+func (gs *GameState) turnStatus() (turn int, open bool, remaining time.Duration, orders, limit int) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turns.turn, gs.turns.open, time.Until(gs.turns.deadline), gs.turns.orders, gs.turns.limit
+}
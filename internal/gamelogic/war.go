@@ -14,27 +14,99 @@ const (
 	WarOutcomeDraw
 )
 
+// Location is where the attacker ran into the defender.
+func (rw RecognitionOfWar) Location() Location {
+	return getOverlappingLocation(rw.Attacker, rw.Defender)
+}
+
+// At strips a war down to the units at loc, so passing it on to the whole
+// game shows nothing else of anyone's army. Allies with nothing there are
+// left out.
+func (rw RecognitionOfWar) At(loc Location) RecognitionOfWar {
+	at := RecognitionOfWar{
+		Attacker: unitsAt(rw.Attacker, loc),
+		Defender: unitsAt(rw.Defender, loc),
+		Allies:   []Player{},
+	}
+	for _, ally := range rw.Allies {
+		if p := unitsAt(ally, loc); len(p.Units) > 0 {
+			at.Allies = append(at.Allies, p)
+		}
+	}
+	return at
+}
+
+// Power is how strong each side is where the war is fought. The defender's
+// allies fight on the defender's side.
+func (rw RecognitionOfWar) Power() (attacker, defender int) {
+	loc := rw.Location()
+	attacker = powerAt(rw.Attacker, loc)
+	defender = powerAt(rw.Defender, loc)
+	for _, ally := range rw.Allies {
+		if ally.Username == rw.Attacker.Username || ally.Username == rw.Defender.Username {
+			continue
+		}
+		defender += powerAt(ally, loc)
+	}
+	return attacker, defender
+}
+
+// Winner is who wins the war, or "" when it is a draw.
+func (rw RecognitionOfWar) Winner() string {
+	attacker, defender := rw.Power()
+	switch {
+	case attacker > defender:
+		return rw.Attacker.Username
+	case defender > attacker:
+		return rw.Defender.Username
+	}
+	return ""
+}
+
+func powerAt(p Player, loc Location) int {
+	units := []Unit{}
+	for _, u := range unitsAt(p, loc).Units {
+		units = append(units, u)
+	}
+	return unitsToPowerLevel(units)
+}
+
+func unitsAt(p Player, loc Location) Player {
+	at := Player{Username: p.Username, Units: map[int]Unit{}}
+	for id, u := range p.Units {
+		if u.Location == loc {
+			at.Units[id] = u
+		}
+	}
+	return at
+}
+
 func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
-	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
+	defer fmt.Fprintln(gs.Output(), "------------------------")
+	fmt.Fprintln(gs.Output())
+	fmt.Fprintln(gs.Output(), "==== War Declared ====")
+	fmt.Fprintf(gs.Output(), "%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
 
 	player := gs.GetPlayerSnap()
 
 	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
+		fmt.Fprintf(gs.Output(), "%s, you published the war.\n", player.Username)
 		return WarOutcomeNotInvolved, "", ""
 	}
 
 	if player.Username != rw.Attacker.Username {
-		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
+		fmt.Fprintf(gs.Output(), "%s, you are not involved in this war.\n", player.Username)
 		return WarOutcomeNotInvolved, "", ""
 	}
 
+	if gs.isAlly(rw.Defender.Username) {
+		fmt.Fprintf(gs.Output(), "You are allied with %s. No war will be fought.\n", rw.Defender.Username)
+		return WarOutcomeNoUnits, "", ""
+	}
+
 	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
 	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
+		fmt.Fprintf(gs.Output(), "Error! No units are in the same location. No war will be fought.\n")
 		return WarOutcomeNoUnits, "", ""
 	}
 
@@ -51,39 +123,56 @@ func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner
 		}
 	}
 
-	fmt.Printf("%s's units:\n", rw.Attacker.Username)
+	fmt.Fprintf(gs.Output(), "%s's units:\n", rw.Attacker.Username)
 	for _, unit := range attackerUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+		fmt.Fprintf(gs.Output(), "  * %v\n", unit.Rank)
 	}
-	fmt.Printf("%s's units:\n", rw.Defender.Username)
+	fmt.Fprintf(gs.Output(), "%s's units:\n", rw.Defender.Username)
 	for _, unit := range defenderUnits {
-		fmt.Printf("  * %v\n", unit.Rank)
+		fmt.Fprintf(gs.Output(), "  * %v\n", unit.Rank)
+	}
+	attackerPower, defenderPower := rw.Power()
+	for _, ally := range rw.Allies {
+		if ally.Username == rw.Attacker.Username || ally.Username == rw.Defender.Username {
+			continue
+		}
+		allyUnits := []Unit{}
+		for _, unit := range ally.Units {
+			if unit.Location == overlappingLocation {
+				allyUnits = append(allyUnits, unit)
+			}
+		}
+		if len(allyUnits) == 0 {
+			continue
+		}
+		fmt.Fprintf(gs.Output(), "%s's allied units:\n", ally.Username)
+		for _, unit := range allyUnits {
+			fmt.Fprintf(gs.Output(), "  * %v\n", unit.Rank)
+		}
 	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
+	fmt.Fprintf(gs.Output(), "Attacker has a power level of %v\n", attackerPower)
+	fmt.Fprintf(gs.Output(), "Defender has a power level of %v\n", defenderPower)
 	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
+		fmt.Fprintf(gs.Output(), "%s has won the war!\n", rw.Attacker.Username)
 		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
+			fmt.Fprintln(gs.Output(), "You have lost the war!")
 			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
+			fmt.Fprintf(gs.Output(), "Your units in %s have been killed.\n", overlappingLocation)
 			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
 		}
 		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
 	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
+		fmt.Fprintf(gs.Output(), "%s has won the war!\n", rw.Defender.Username)
 		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
+			fmt.Fprintln(gs.Output(), "You have lost the war!")
 			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
+			fmt.Fprintf(gs.Output(), "Your units in %s have been killed.\n", overlappingLocation)
 			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
 		}
 		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
 	}
-	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
+	fmt.Fprintln(gs.Output(), "The war ended in a draw!")
+	fmt.Fprintf(gs.Output(), "Your units in %s have been killed.\n", overlappingLocation)
 	gs.removeUnitsInLocation(overlappingLocation)
 	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
 }
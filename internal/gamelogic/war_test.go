@@ -0,0 +1,121 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// A war is passed on to every client in the game, so it must carry nothing
+// of anyone's army outside the location it's fought in.
+func TestRecognitionOfWarAt(t *testing.T) {
+	rw := RecognitionOfWar{
+		Attacker: Player{Username: "alice", Units: map[int]Unit{
+			1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+			2: {ID: 2, Rank: RankArtillery, Location: "antarctica"},
+		}},
+		Defender: Player{Username: "bob", Units: map[int]Unit{
+			1: {ID: 1, Rank: RankCavalry, Location: "europe"},
+			2: {ID: 2, Rank: RankInfantry, Location: "australia"},
+		}},
+		Allies: []Player{
+			{Username: "carol", Units: map[int]Unit{
+				1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+				2: {ID: 2, Rank: RankCavalry, Location: "americas"},
+			}},
+			{Username: "dave", Units: map[int]Unit{
+				1: {ID: 1, Rank: RankInfantry, Location: "asia"},
+			}},
+		},
+	}
+
+	at := rw.At(rw.Location())
+	if loc := at.Location(); loc != "europe" {
+		t.Fatalf("war is in %q, want europe", loc)
+	}
+	players := append([]Player{at.Attacker, at.Defender}, at.Allies...)
+	for _, p := range players {
+		for _, u := range p.Units {
+			if u.Location != "europe" {
+				t.Errorf("war carries %s's %s in %s", p.Username, u.Rank, u.Location)
+			}
+		}
+	}
+	if len(at.Attacker.Units) != 1 || len(at.Defender.Units) != 1 {
+		t.Errorf("war lost the units fighting it: %+v", at)
+	}
+	if len(at.Allies) != 1 || at.Allies[0].Username != "carol" {
+		t.Errorf("allies = %+v, want only carol, who has units in europe", at.Allies)
+	}
+
+	data, err := json.Marshal(at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, loc := range []string{"antarctica", "australia", "americas", "asia", "dave"} {
+		if strings.Contains(string(data), loc) {
+			t.Errorf("war message mentions %s: %s", loc, data)
+		}
+	}
+}
+
+func TestRecognitionOfWarWinner(t *testing.T) {
+	army := func(username string, ranks ...UnitRank) Player {
+		p := Player{Username: username, Units: map[int]Unit{}}
+		for i, r := range ranks {
+			p.Units[i+1] = Unit{ID: i + 1, Rank: r, Location: "europe"}
+		}
+		return p
+	}
+	elsewhere := army("alice", RankInfantry)
+	elsewhere.Units[99] = Unit{ID: 99, Rank: RankArtillery, Location: "asia"}
+	tests := []struct {
+		name string
+		rw   RecognitionOfWar
+		want string
+	}{
+		{
+			name: "stronger attacker",
+			rw:   RecognitionOfWar{Attacker: army("alice", RankCavalry), Defender: army("bob", RankInfantry)},
+			want: "alice",
+		},
+		{
+			name: "stronger defender",
+			rw:   RecognitionOfWar{Attacker: army("alice", RankInfantry), Defender: army("bob", RankArtillery)},
+			want: "bob",
+		},
+		{
+			name: "units elsewhere don't fight",
+			rw:   RecognitionOfWar{Attacker: elsewhere, Defender: army("bob", RankCavalry)},
+			want: "bob",
+		},
+		{
+			name: "draw",
+			rw:   RecognitionOfWar{Attacker: army("alice", RankCavalry), Defender: army("bob", RankInfantry, RankInfantry, RankInfantry, RankInfantry, RankInfantry)},
+			want: "",
+		},
+		{
+			name: "allies defend",
+			rw: RecognitionOfWar{
+				Attacker: army("alice", RankCavalry),
+				Defender: army("bob", RankInfantry),
+				Allies:   []Player{army("carol", RankCavalry)},
+			},
+			want: "bob",
+		},
+		{
+			name: "the fighters are not their own allies",
+			rw: RecognitionOfWar{
+				Attacker: army("alice", RankCavalry),
+				Defender: army("bob", RankInfantry),
+				Allies:   []Player{army("bob", RankInfantry), army("alice", RankArtillery)},
+			},
+			want: "alice",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.rw.Winner(); got != tt.want {
+			t.Errorf("%s: winner = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
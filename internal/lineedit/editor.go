@@ -0,0 +1,421 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when ctrl-c is pressed on an empty
+// line.
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns candidates for the word under the cursor. words holds the
+// complete words before it and prefix what has been typed of it so far.
+type Completer func(words []string, prefix string) []string
+
+// Editor reads lines from a terminal with cursor movement, history and tab
+// completion. Output written through it while a line is being edited is
+// printed above the prompt, which is then redrawn with the partial line.
+type Editor struct {
+	prompt   string
+	fd       int
+	in       *bufio.Reader
+	out      io.Writer
+	complete Completer
+	history  *history
+
+	mu      *sync.Mutex
+	active  bool
+	midLine bool
+	buf     []rune
+	pos     int
+	pending []byte
+	restore func() error
+	print   func(out io.Writer, lines []byte)
+
+	stdout  *os.File
+	capture *os.File
+	drained chan struct{}
+}
+
+func New(prompt string) *Editor {
+	return &Editor{
+		prompt:  prompt,
+		fd:      int(os.Stdin.Fd()),
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		history: &history{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (e *Editor) SetCompleter(c Completer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.complete = c
+}
+
+// SetPrinter hands output written through the editor to print, whole lines
+// at a time, instead of printing it above the prompt. print may draw anywhere
+// as long as it leaves the cursor on the prompt's row.
+func (e *Editor) SetPrinter(print func(out io.Writer, lines []byte)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.print = print
+}
+
+// Redraw runs draw with the terminal and then redraws the line being edited,
+// so the rest of the screen can be repainted without garbling it.
+func (e *Editor) Redraw(draw func(out io.Writer)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	draw(e.out)
+	if e.active {
+		e.refresh()
+	}
+}
+
+// LoadHistory reads the history kept in path and appends every later line
+// to it.
+func (e *Editor) LoadHistory(path string) error {
+	h, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.history = h
+	return nil
+}
+
+// CaptureStdout routes everything printed to os.Stdout through the editor so
+// it can't break up the line being typed.
+func (e *Editor) CaptureStdout() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("could not create stdout pipe: %v", err)
+	}
+	e.stdout = os.Stdout
+	e.out = e.stdout
+	e.capture = w
+	e.drained = make(chan struct{})
+	os.Stdout = w
+	go func() {
+		defer close(e.drained)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				e.Write(buf[:n])
+			}
+			if err != nil {
+				r.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close puts stdout and the terminal back the way they were, after printing
+// anything still waiting in the capture pipe.
+func (e *Editor) Close() {
+	if e.capture != nil {
+		os.Stdout = e.stdout
+		e.capture.Close()
+		<-e.drained
+		e.capture = nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) > 0 {
+		e.out.Write(e.pending)
+		e.pending = nil
+	}
+	if e.restore != nil {
+		e.restore()
+		e.restore = nil
+	}
+}
+
+// Write prints p above the line being edited, or straight through when no
+// line is.
+func (e *Editor) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.print != nil {
+		e.pending = append(e.pending, p...)
+		if i := bytes.LastIndexByte(e.pending, '\n'); i >= 0 {
+			e.print(e.out, e.pending[:i+1])
+			e.pending = append([]byte{}, e.pending[i+1:]...)
+		}
+		if e.active {
+			e.refresh()
+		}
+		return len(p), nil
+	}
+	if !e.active {
+		if len(p) > 0 {
+			e.midLine = p[len(p)-1] != '\n'
+		}
+		return e.out.Write(p)
+	}
+	// hold back a trailing partial line so the prompt isn't drawn into it
+	e.pending = append(e.pending, p...)
+	i := bytes.LastIndexByte(e.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	io.WriteString(e.out, "\r\x1b[K")
+	e.out.Write(e.pending[:i+1])
+	e.pending = append([]byte{}, e.pending[i+1:]...)
+	e.refresh()
+	return len(p), nil
+}
+
+// ReadLine edits one line and returns it without the newline. It returns
+// io.EOF on ctrl-d and ErrInterrupted on ctrl-c when the line is empty.
+func (e *Editor) ReadLine() (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	e.mu.Lock()
+	e.restore = restore
+	e.active = true
+	e.buf = nil
+	e.pos = 0
+	e.history.rewind()
+	if e.midLine {
+		io.WriteString(e.out, "\n")
+		e.midLine = false
+	}
+	e.refresh()
+	e.mu.Unlock()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			e.finish()
+			return "", err
+		}
+		e.mu.Lock()
+		line, done, err := e.key(r)
+		e.mu.Unlock()
+		if done {
+			e.finish()
+			if err == nil {
+				e.history.add(line)
+			}
+			return line, err
+		}
+	}
+}
+
+func (e *Editor) finish() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active = false
+	if e.print != nil {
+		// the printer owns the screen, so leave the prompt's row in place
+		io.WriteString(e.out, "\r\x1b[K")
+	} else {
+		e.pos = len(e.buf)
+		e.refresh()
+		io.WriteString(e.out, "\n")
+		if len(e.pending) > 0 {
+			e.out.Write(e.pending)
+			e.midLine = true
+			e.pending = nil
+		}
+	}
+	if e.restore != nil {
+		e.restore()
+		e.restore = nil
+	}
+}
+
+// key handles one keypress and reports whether the line is finished.
+func (e *Editor) key(r rune) (string, bool, error) {
+	switch r {
+	case '\r', '\n':
+		return string(e.buf), true, nil
+	case ctrl('c'):
+		if len(e.buf) == 0 {
+			return "", true, ErrInterrupted
+		}
+		e.buf, e.pos = nil, 0
+	case ctrl('d'):
+		if len(e.buf) == 0 {
+			return "", true, io.EOF
+		}
+		e.delete(e.pos, e.pos+1)
+	case '\t':
+		e.completeWord()
+	case 127, ctrl('h'):
+		e.delete(e.pos-1, e.pos)
+	case ctrl('a'):
+		e.pos = 0
+	case ctrl('e'):
+		e.pos = len(e.buf)
+	case ctrl('b'):
+		e.move(-1)
+	case ctrl('f'):
+		e.move(1)
+	case ctrl('k'):
+		e.buf = e.buf[:e.pos]
+	case ctrl('u'):
+		e.delete(0, e.pos)
+	case ctrl('w'):
+		start := e.pos
+		for start > 0 && unicode.IsSpace(e.buf[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+			start--
+		}
+		e.delete(start, e.pos)
+	case ctrl('p'):
+		e.recall(e.history.prev(string(e.buf)))
+	case ctrl('n'):
+		e.recall(e.history.next())
+	case ctrl('l'):
+		io.WriteString(e.out, "\x1b[H\x1b[2J")
+	case 27:
+		e.escape()
+	default:
+		if !unicode.IsPrint(r) {
+			return "", false, nil
+		}
+		e.insert([]rune{r})
+	}
+	e.refresh()
+	return "", false, nil
+}
+
+// escape handles the arrow, home, end and delete keys, which arrive as
+// escape sequences.
+func (e *Editor) escape() {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	params := ""
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+		params += string(r)
+	}
+	switch {
+	case r == 'A':
+		e.recall(e.history.prev(string(e.buf)))
+	case r == 'B':
+		e.recall(e.history.next())
+	case r == 'C':
+		e.move(1)
+	case r == 'D':
+		e.move(-1)
+	case r == 'H', r == '~' && (params == "1" || params == "7"):
+		e.pos = 0
+	case r == 'F', r == '~' && (params == "4" || params == "8"):
+		e.pos = len(e.buf)
+	case r == '~' && params == "3":
+		e.delete(e.pos, e.pos+1)
+	}
+}
+
+func (e *Editor) insert(rs []rune) {
+	buf := append([]rune{}, e.buf[:e.pos]...)
+	buf = append(buf, rs...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(rs)
+}
+
+func (e *Editor) delete(from, to int) {
+	if from < 0 || to > len(e.buf) || from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	if e.pos > from {
+		e.pos = max(from, e.pos-(to-from))
+	}
+}
+
+func (e *Editor) move(n int) {
+	e.pos = min(max(e.pos+n, 0), len(e.buf))
+}
+
+func (e *Editor) recall(line string, ok bool) {
+	if !ok {
+		return
+	}
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+// completeWord extends the word under the cursor as far as every candidate
+// agrees, and lists the candidates when it can't go any further.
+func (e *Editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	before := string(e.buf[:e.pos])
+	start := strings.LastIndexAny(before, " \t") + 1
+	prefix := before[start:]
+	matches := []string{}
+	for _, c := range e.complete(strings.Fields(before[:start]), prefix) {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+	insert := commonPrefix(matches)[len(prefix):]
+	if len(matches) == 1 {
+		insert += " "
+	}
+	if insert != "" {
+		e.insert([]rune(insert))
+		return
+	}
+	io.WriteString(e.out, "\r\x1b[K"+strings.Join(matches, "  ")+"\n")
+}
+
+func (e *Editor) refresh() {
+	var b strings.Builder
+	b.WriteString("\r\x1b[K")
+	b.WriteString(e.prompt)
+	b.WriteString(string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
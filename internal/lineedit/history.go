@@ -0,0 +1,109 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxHistory = 500
+
+type history struct {
+	lines []string
+	path  string
+	// at is the line being recalled, len(lines) when editing a new one
+	at    int
+	draft string
+}
+
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open history: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read history: %v", err)
+	}
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+		if err := h.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	h.rewind()
+	return h, nil
+}
+
+func (h *history) rewind() {
+	h.at = len(h.lines)
+	h.draft = ""
+}
+
+// prev steps back through history, keeping the line being typed so next can
+// return to it.
+func (h *history) prev(current string) (string, bool) {
+	if h.at == 0 {
+		return "", false
+	}
+	if h.at == len(h.lines) {
+		h.draft = current
+	}
+	h.at--
+	return h.lines[h.at], true
+}
+
+func (h *history) next() (string, bool) {
+	if h.at >= len(h.lines) {
+		return "", false
+	}
+	h.at++
+	if h.at == len(h.lines) {
+		return h.draft, true
+	}
+	return h.lines[h.at], true
+}
+
+// add remembers a line unless it is blank or repeats the last one. History
+// is a convenience, so failing to save it is not reported.
+func (h *history) add(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (h *history) rewrite() error {
+	data := strings.Join(h.lines, "\n") + "\n"
+	if err := os.WriteFile(h.path, []byte(data), 0600); err != nil {
+		return fmt.Errorf("could not write history: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package lineedit
+
+import "errors"
+
+func IsTerminal(fd int) bool {
+	return false
+}
+
+func Size(fd int) (int, int, error) {
+	return 0, 0, errors.New("terminal size is not available on this platform")
+}
+
+func makeRaw(fd int) (func() error, error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
@@ -0,0 +1,301 @@
+package lobby
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const (
+	MinGameSize = 2
+	MaxGameSize = 8
+)
+
+type game struct {
+	id      string
+	host    string
+	size    int
+	players []string
+	ready   map[string]bool
+	started bool
+}
+
+func (g *game) summary() routing.GameSummary {
+	ready := []string{}
+	for _, p := range g.players {
+		if g.ready[p] {
+			ready = append(ready, p)
+		}
+	}
+	return routing.GameSummary{
+		GameID:  g.id,
+		Host:    g.host,
+		Size:    g.size,
+		Players: append([]string{}, g.players...),
+		Ready:   ready,
+		Started: g.started,
+	}
+}
+
+func (g *game) full() bool {
+	return len(g.players) >= g.size
+}
+
+func (g *game) allReady() bool {
+	if !g.full() {
+		return false
+	}
+	for _, p := range g.players {
+		if !g.ready[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// Lobby tracks players who have announced themselves and the games they are
+// forming. A game starts once it is full and every player passed the ready
+// check.
+type Lobby struct {
+	players map[string]string // username -> game ID, "" while unassigned
+	games   map[string]*game
+	mu      *sync.Mutex
+}
+
+func New() *Lobby {
+	return &Lobby{
+		players: map[string]string{},
+		games:   map[string]*game{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (l *Lobby) Announce(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.players[username]; !ok {
+		l.players[username] = ""
+	}
+}
+
+func (l *Lobby) OpenGames() []routing.GameSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	summaries := []routing.GameSummary{}
+	for _, g := range l.games {
+		if g.started || g.full() {
+			continue
+		}
+		summaries = append(summaries, g.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].GameID < summaries[j].GameID
+	})
+	return summaries
+}
+
+func (l *Lobby) Games() []routing.GameSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	summaries := []routing.GameSummary{}
+	for _, g := range l.games {
+		summaries = append(summaries, g.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].GameID < summaries[j].GameID
+	})
+	return summaries
+}
+
+func (l *Lobby) Game(gameID string) (routing.GameSummary, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	g, ok := l.games[gameID]
+	if !ok {
+		return routing.GameSummary{}, false
+	}
+	return g.summary(), true
+}
+
+func (l *Lobby) Create(username string, size int) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := validateSize(size); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if err := l.checkUnassigned(username); err != nil {
+		return routing.GameSummary{}, err
+	}
+	g := l.newGame(username, size)
+	return g.summary(), nil
+}
+
+func (l *Lobby) Join(username, gameID string) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.checkUnassigned(username); err != nil {
+		return routing.GameSummary{}, err
+	}
+	g, ok := l.games[gameID]
+	if !ok {
+		return routing.GameSummary{}, fmt.Errorf("game %s does not exist", gameID)
+	}
+	if g.started {
+		return routing.GameSummary{}, fmt.Errorf("game %s has already started", gameID)
+	}
+	if g.full() {
+		return routing.GameSummary{}, fmt.Errorf("game %s is full", gameID)
+	}
+	l.addPlayer(g, username)
+	return g.summary(), nil
+}
+
+// AutoMatch puts the player in the fullest open game of the requested size,
+// creating one if none is open.
+func (l *Lobby) AutoMatch(username string, size int) (routing.GameSummary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := validateSize(size); err != nil {
+		return routing.GameSummary{}, err
+	}
+	if err := l.checkUnassigned(username); err != nil {
+		return routing.GameSummary{}, err
+	}
+	var best *game
+	for _, g := range l.games {
+		if g.started || g.full() || g.size != size {
+			continue
+		}
+		if best == nil || len(g.players) > len(best.players) || (len(g.players) == len(best.players) && g.id < best.id) {
+			best = g
+		}
+	}
+	if best == nil {
+		best = l.newGame(username, size)
+		return best.summary(), nil
+	}
+	l.addPlayer(best, username)
+	return best.summary(), nil
+}
+
+// Ready marks the player ready and reports whether that started the game.
+func (l *Lobby) Ready(username string) (routing.GameSummary, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	g, err := l.gameOf(username)
+	if err != nil {
+		return routing.GameSummary{}, false, err
+	}
+	if g.started {
+		return routing.GameSummary{}, false, fmt.Errorf("game %s has already started", g.id)
+	}
+	g.ready[username] = true
+	if g.allReady() {
+		g.started = true
+		return g.summary(), true, nil
+	}
+	return g.summary(), false, nil
+}
+
+// CancelStart undoes the start Ready reported when the game could not be
+// started, so the last ready can be tried again.
+func (l *Lobby) CancelStart(gameID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if g, ok := l.games[gameID]; ok {
+		g.started = false
+	}
+}
+
+// Leave removes the player from their game, if any. Empty games are dropped.
+func (l *Lobby) Leave(username string) (routing.GameSummary, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	g, err := l.gameOf(username)
+	if err != nil {
+		delete(l.players, username)
+		return routing.GameSummary{}, false
+	}
+	delete(l.players, username)
+	delete(g.ready, username)
+	for i, p := range g.players {
+		if p == username {
+			g.players = append(g.players[:i], g.players[i+1:]...)
+			break
+		}
+	}
+	// a departure reopens the ready check for everyone else
+	if !g.started {
+		g.ready = map[string]bool{}
+	}
+	if len(g.players) == 0 {
+		delete(l.games, g.id)
+	} else if g.host == username {
+		g.host = g.players[0]
+	}
+	return g.summary(), true
+}
+
+// Finish drops a game that has ended and frees its players for a new one.
+func (l *Lobby) Finish(gameID string) (routing.GameSummary, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	g, ok := l.games[gameID]
+	if !ok {
+		return routing.GameSummary{}, false
+	}
+	for _, p := range g.players {
+		delete(l.players, p)
+	}
+	delete(l.games, gameID)
+	return g.summary(), true
+}
+
+func (l *Lobby) newGame(host string, size int) *game {
+	id := routing.NewGameID()
+	for _, exists := l.games[id]; exists; _, exists = l.games[id] {
+		id = routing.NewGameID()
+	}
+	g := &game{
+		id:    id,
+		host:  host,
+		size:  size,
+		ready: map[string]bool{},
+	}
+	l.games[id] = g
+	l.addPlayer(g, host)
+	return g
+}
+
+func (l *Lobby) addPlayer(g *game, username string) {
+	g.players = append(g.players, username)
+	l.players[username] = g.id
+}
+
+func (l *Lobby) checkUnassigned(username string) error {
+	if gameID := l.players[username]; gameID != "" {
+		return fmt.Errorf("%s is already in game %s", username, gameID)
+	}
+	return nil
+}
+
+func (l *Lobby) gameOf(username string) (*game, error) {
+	gameID := l.players[username]
+	if gameID == "" {
+		return nil, fmt.Errorf("%s is not in a game", username)
+	}
+	g, ok := l.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("game %s does not exist", gameID)
+	}
+	return g, nil
+}
+
+func validateSize(size int) error {
+	if size < MinGameSize || size > MaxGameSize {
+		return fmt.Errorf("game size must be between %d and %d", MinGameSize, MaxGameSize)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Just enough of MQTT 3.1.1 to publish: connect, publish at QoS 0 or 1,
+// keepalive pings and disconnect.
+
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPubAck     = 4
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+)
+
+const ackTimeout = 10 * time.Second
+
+var ErrClosed = errors.New("mqtt connection closed")
+
+type Options struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+}
+
+// Client publishes to an MQTT broker. It is safe for concurrent use.
+type Client struct {
+	nc      net.Conn
+	r       *bufio.Reader
+	writeMu *sync.Mutex
+
+	mu      *sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan struct{}
+	err     error
+	done    chan struct{}
+}
+
+func Dial(addr string, opts Options) (*Client, error) {
+	nc, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(nc, opts)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient connects over an already open connection.
+func NewClient(nc net.Conn, opts Options) (*Client, error) {
+	c := &Client{
+		nc:      nc,
+		r:       bufio.NewReader(nc),
+		writeMu: &sync.Mutex{},
+		mu:      &sync.Mutex{},
+		pending: map[uint16]chan struct{}{},
+		done:    make(chan struct{}),
+	}
+
+	// clean session, since we never subscribe there is nothing to keep
+	flags := byte(0x02)
+	payload := appendString(nil, opts.ClientID)
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.Username)
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+		payload = appendString(payload, opts.Password)
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive/time.Second))
+	body = append(body, payload...)
+	if err := c.write(packetConnect<<4, body); err != nil {
+		return nil, err
+	}
+
+	kind, body, err := readPacket(c.r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CONNACK: %v", err)
+	}
+	if kind != packetConnAck || len(body) != 2 {
+		return nil, fmt.Errorf("expected CONNACK, got packet type %d", kind)
+	}
+	if code := body[1]; code != 0 {
+		return nil, fmt.Errorf("connection refused: %s", connAckReason(code))
+	}
+
+	go c.readLoop()
+	if opts.KeepAlive > 0 {
+		go c.keepAlive(opts.KeepAlive)
+	}
+	return c, nil
+}
+
+func connAckReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client ID rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
+
+// Publish sends payload to topic. At QoS 1 it waits for the broker to
+// acknowledge it. A retained message is what new subscribers receive first.
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return errors.New("only QoS 0 and 1 are supported")
+	}
+	flags := byte(packetPublish<<4) | qos<<1
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos == 0 {
+		return c.write(flags, append(body, payload...))
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	id := c.nextID
+	acked := make(chan struct{})
+	c.pending[id] = acked
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	body = binary.BigEndian.AppendUint16(body, id)
+	if err := c.write(flags, append(body, payload...)); err != nil {
+		return err
+	}
+	select {
+	case <-acked:
+		return nil
+	case <-c.done:
+		return c.closeErr()
+	case <-time.After(ackTimeout):
+		return fmt.Errorf("no PUBACK for %s", topic)
+	}
+}
+
+// Done is closed once the connection is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Err() error {
+	return c.closeErr()
+}
+
+func (c *Client) Close() error {
+	c.write(packetDisconnect<<4, nil)
+	err := c.nc.Close()
+	<-c.done
+	return err
+}
+
+func (c *Client) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(packetPingReq<<4, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		kind, body, err := readPacket(c.r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch kind {
+		case packetPubAck:
+			if len(body) < 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(body)
+			c.mu.Lock()
+			if acked, ok := c.pending[id]; ok {
+				close(acked)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+		case packetPingResp:
+		}
+	}
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		err = ErrClosed
+	}
+	c.err = err
+}
+
+func (c *Client) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return ErrClosed
+	}
+	return c.err
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	packet := append([]byte{header}, appendLength(nil, len(body))...)
+	packet = append(packet, body...)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.nc.Write(packet)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := readLength(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+// remaining lengths are base 128 varints of at most four bytes
+func appendLength(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	n, shift := 0, 0
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			return n, nil
+		}
+		shift += 7
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,232 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mqtt/mqtttest"
+)
+
+// connect logs a client in to a fake broker that accepts it.
+func connect(t *testing.T, opts Options) (*Client, *mqtttest.Broker) {
+	t.Helper()
+	nc, b := mqtttest.Pipe(t)
+	type result struct {
+		c   *Client
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := NewClient(nc, opts)
+		done <- result{c, err}
+	}()
+	b.Accept()
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("NewClient: %v", res.err)
+	}
+	t.Cleanup(func() {
+		b.Close()
+		res.c.Close()
+	})
+	return res.c, b
+}
+
+func TestConnect(t *testing.T) {
+	nc, b := mqtttest.Pipe(t)
+	defer b.Close()
+	done := make(chan error, 1)
+	go func() {
+		c, err := NewClient(nc, Options{
+			ClientID:  "peril_bridge.1",
+			Username:  "bridge",
+			Password:  "secret",
+			KeepAlive: 30 * time.Second,
+		})
+		if err == nil {
+			defer c.Close()
+		}
+		done <- err
+	}()
+
+	p := b.Expect(mqtttest.PacketConnect)
+	if p.Flags != 0 {
+		t.Errorf("CONNECT flags = %#x, want 0", p.Flags)
+	}
+	proto, rest := mqtttest.ReadString(t, p.Body)
+	if proto != "MQTT" {
+		t.Errorf("protocol name = %q", proto)
+	}
+	if level := rest[0]; level != 4 {
+		t.Errorf("protocol level = %d, want 4 for 3.1.1", level)
+	}
+	// username, password and clean session
+	if flags := rest[1]; flags != 0xc2 {
+		t.Errorf("connect flags = %#x, want 0xc2", flags)
+	}
+	if keepAlive := binary.BigEndian.Uint16(rest[2:]); keepAlive != 30 {
+		t.Errorf("keep alive = %d, want 30", keepAlive)
+	}
+	rest = rest[4:]
+	for _, want := range []string{"peril_bridge.1", "bridge", "secret"} {
+		var got string
+		got, rest = mqtttest.ReadString(t, rest)
+		if got != want {
+			t.Errorf("payload field = %q, want %q", got, want)
+		}
+	}
+	if len(rest) != 0 {
+		t.Errorf("CONNECT has %d bytes left over", len(rest))
+	}
+
+	b.ConnAck(mqtttest.ConnAccepted)
+	if err := <-done; err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+}
+
+func TestConnectAnonymous(t *testing.T) {
+	nc, b := mqtttest.Pipe(t)
+	defer b.Close()
+	go NewClient(nc, Options{ClientID: "c"})
+	p := b.Expect(mqtttest.PacketConnect)
+	_, rest := mqtttest.ReadString(t, p.Body)
+	if flags := rest[1]; flags != 0x02 {
+		t.Errorf("connect flags = %#x, want only clean session", flags)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	nc, b := mqtttest.Pipe(t)
+	defer b.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewClient(nc, Options{ClientID: "c", Username: "bridge", Password: "wrong"})
+		done <- err
+	}()
+	b.Expect(mqtttest.PacketConnect)
+	b.ConnAck(mqtttest.ConnBadCredentials)
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "bad username or password") {
+		t.Fatalf("NewClient error = %v, want the CONNACK reason", err)
+	}
+}
+
+func TestPublishQoS1WaitsForPubAck(t *testing.T) {
+	c, b := connect(t, Options{ClientID: "c"})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Publish("peril/g1/territory/europe", []byte("alice"), 1, true)
+	}()
+	pub := b.ExpectPublish()
+	if pub.Topic != "peril/g1/territory/europe" || pub.QoS != 1 || !pub.Retain {
+		t.Fatalf("PUBLISH = %+v, want QoS 1 retained to the location", pub)
+	}
+	if pub.ID == 0 {
+		t.Fatal("QoS 1 PUBLISH has packet ID 0")
+	}
+	if string(pub.Payload) != "alice" {
+		t.Errorf("payload = %q", pub.Payload)
+	}
+
+	// a PUBACK for some other packet doesn't complete this one
+	b.PubAck(pub.ID + 1)
+	select {
+	case err := <-done:
+		t.Fatalf("Publish returned %v before its PUBACK", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.PubAck(pub.ID)
+	if err := <-done; err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// every QoS 1 publish gets its own packet ID
+	go func() {
+		done <- c.Publish("peril/g1/war", []byte("{}"), 1, false)
+	}()
+	next := b.ExpectPublish()
+	if next.ID == pub.ID {
+		t.Errorf("packet ID %d was reused", next.ID)
+	}
+	if next.Retain {
+		t.Error("unretained PUBLISH has the retain flag")
+	}
+	b.PubAck(next.ID)
+	if err := <-done; err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+}
+
+func TestPublishQoS0(t *testing.T) {
+	c, b := connect(t, Options{ClientID: "c"})
+
+	// long enough to need two bytes of remaining length
+	payload := bytes.Repeat([]byte("x"), 300)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Publish("peril/all/pause", payload, 0, true)
+	}()
+	pub := b.ExpectPublish()
+	if err := <-done; err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if pub.Topic != "peril/all/pause" || pub.QoS != 0 || !pub.Retain {
+		t.Errorf("PUBLISH = %s QoS %d retain %v", pub.Topic, pub.QoS, pub.Retain)
+	}
+	if !bytes.Equal(pub.Payload, payload) {
+		t.Errorf("payload has %d bytes, want %d", len(pub.Payload), len(payload))
+	}
+}
+
+func TestPublishRejectsQoS2(t *testing.T) {
+	c, _ := connect(t, Options{ClientID: "c"})
+	if err := c.Publish("t", nil, 2, false); err == nil {
+		t.Fatal("Publish at QoS 2 succeeded")
+	}
+}
+
+func TestPublishFailsWhenBrokerGoes(t *testing.T) {
+	c, b := connect(t, Options{ClientID: "c"})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Publish("t", []byte("x"), 1, false)
+	}()
+	b.ExpectPublish()
+	b.Close()
+	if err := <-done; !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish error = %v, want ErrClosed", err)
+	}
+	<-c.Done()
+	if err := c.Publish("t", []byte("x"), 1, false); err == nil {
+		t.Fatal("Publish after the broker went succeeded")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	_, b := connect(t, Options{ClientID: "c", KeepAlive: 100 * time.Millisecond})
+	b.Expect(mqtttest.PacketPingReq)
+	b.Write(mqtttest.PacketPingResp, nil)
+	b.Expect(mqtttest.PacketPingReq)
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, 268435455} {
+		encoded := appendLength(nil, n)
+		got, err := readLength(bufio.NewReader(bytes.NewReader(encoded)))
+		if err != nil || got != n {
+			t.Errorf("length %d encoded as %x reads back as %d, %v", n, encoded, got, err)
+		}
+	}
+	if _, err := readLength(bufio.NewReader(bytes.NewReader([]byte{0x80, 0x80, 0x80, 0x80, 0x01}))); err == nil {
+		t.Error("a five byte remaining length was accepted")
+	}
+}
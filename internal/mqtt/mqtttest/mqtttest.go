@@ -0,0 +1,175 @@
+// Package mqtttest is a fake MQTT 3.1.1 broker for tests of code that
+// publishes with package mqtt. It decodes packets on its own, so it doesn't
+// share mistakes with the client it tests.
+package mqtttest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// Packet types, from the high nibble of a packet's first byte.
+const (
+	PacketConnect    = 1
+	PacketConnAck    = 2
+	PacketPublish    = 3
+	PacketPubAck     = 4
+	PacketPingReq    = 12
+	PacketPingResp   = 13
+	PacketDisconnect = 14
+)
+
+// CONNACK return codes.
+const (
+	ConnAccepted       = 0
+	ConnBadCredentials = 4
+)
+
+// Broker is the broker's end of a net.Pipe, run on the test goroutine.
+// Client calls that write to the pipe run in their own goroutines until the
+// broker reads what they wrote.
+type Broker struct {
+	t  testing.TB
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+// Packet is a packet as the broker received it.
+type Packet struct {
+	Kind  byte
+	Flags byte
+	Body  []byte
+}
+
+// Publish is a PUBLISH packet as the broker received it.
+type Publish struct {
+	Topic   string
+	QoS     byte
+	Retain  bool
+	ID      uint16
+	Payload []byte
+}
+
+// Pipe returns the client's end of a connection to a new broker. Both ends
+// give up after five seconds, so a test that hangs fails instead.
+func Pipe(t testing.TB) (net.Conn, *Broker) {
+	t.Helper()
+	client, server := net.Pipe()
+	deadline := time.Now().Add(5 * time.Second)
+	client.SetDeadline(deadline)
+	server.SetDeadline(deadline)
+	return client, &Broker{t: t, nc: server, r: bufio.NewReader(server)}
+}
+
+// Close drops the connection, as a broker going away would.
+func (b *Broker) Close() error {
+	return b.nc.Close()
+}
+
+func (b *Broker) Read() Packet {
+	b.t.Helper()
+	header, err := b.r.ReadByte()
+	if err != nil {
+		b.t.Fatalf("broker could not read a packet: %v", err)
+	}
+	n, shift := 0, 0
+	for i := 0; ; i++ {
+		if i == 4 {
+			b.t.Fatal("broker read a remaining length longer than four bytes")
+		}
+		digit, err := b.r.ReadByte()
+		if err != nil {
+			b.t.Fatalf("broker could not read a length: %v", err)
+		}
+		n |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(b.r, body); err != nil {
+		b.t.Fatalf("broker could not read a body: %v", err)
+	}
+	return Packet{Kind: header >> 4, Flags: header & 0x0f, Body: body}
+}
+
+func (b *Broker) Expect(kind byte) Packet {
+	b.t.Helper()
+	p := b.Read()
+	if p.Kind != kind {
+		b.t.Fatalf("broker got packet type %d, want %d", p.Kind, kind)
+	}
+	return p
+}
+
+func (b *Broker) Write(kind byte, body []byte) {
+	b.t.Helper()
+	packet := []byte{kind << 4}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	if _, err := b.nc.Write(append(packet, body...)); err != nil {
+		b.t.Fatalf("broker could not write packet type %d: %v", kind, err)
+	}
+}
+
+// Accept reads a CONNECT and lets the client in.
+func (b *Broker) Accept() Packet {
+	b.t.Helper()
+	p := b.Expect(PacketConnect)
+	b.ConnAck(ConnAccepted)
+	return p
+}
+
+func (b *Broker) ConnAck(code byte) {
+	b.t.Helper()
+	b.Write(PacketConnAck, []byte{0, code})
+}
+
+func (b *Broker) PubAck(id uint16) {
+	b.t.Helper()
+	b.Write(PacketPubAck, binary.BigEndian.AppendUint16(nil, id))
+}
+
+func (b *Broker) ExpectPublish() Publish {
+	b.t.Helper()
+	p := b.Expect(PacketPublish)
+	pub := Publish{QoS: p.Flags >> 1 & 0x03, Retain: p.Flags&0x01 != 0}
+	topic, rest := ReadString(b.t, p.Body)
+	pub.Topic = topic
+	if pub.QoS > 0 {
+		if len(rest) < 2 {
+			b.t.Fatalf("PUBLISH to %s at QoS %d has no packet ID", topic, pub.QoS)
+		}
+		pub.ID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	pub.Payload = rest
+	return pub
+}
+
+// ReadString reads a length prefixed string off the front of b.
+func ReadString(t testing.TB, b []byte) (string, []byte) {
+	t.Helper()
+	if len(b) < 2 {
+		t.Fatalf("string is cut short: %x", b)
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		t.Fatalf("string of %d bytes is cut short: %x", n, b)
+	}
+	return string(b[2 : 2+n]), b[2+n:]
+}
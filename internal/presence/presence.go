@@ -0,0 +1,101 @@
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Player struct {
+	Username string
+	GameID   string
+	LastSeen time.Time
+	Online   bool
+}
+
+// Tracker records the last heartbeat of every player and decides who is
+// online. A player goes offline after missing heartbeats for the timeout.
+type Tracker struct {
+	players map[string]Player
+	timeout time.Duration
+	mu      *sync.Mutex
+}
+
+func NewTracker(timeout time.Duration) *Tracker {
+	return &Tracker{
+		players: map[string]Player{},
+		timeout: timeout,
+		mu:      &sync.Mutex{},
+	}
+}
+
+// Beat records a heartbeat and reports whether the player just came online.
+func (t *Tracker) Beat(username, gameID string, at time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.players[username]
+	joined := !ok || !p.Online
+	t.players[username] = Player{
+		Username: username,
+		GameID:   gameID,
+		LastSeen: at,
+		Online:   true,
+	}
+	return joined
+}
+
+// Leave marks the player offline and reports whether they were online.
+func (t *Tracker) Leave(username string) (Player, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.players[username]
+	if !ok || !p.Online {
+		return p, false
+	}
+	p.Online = false
+	t.players[username] = p
+	return p, true
+}
+
+// Sweep marks every player whose heartbeats stopped as offline and returns
+// them.
+func (t *Tracker) Sweep(now time.Time) []Player {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	gone := []Player{}
+	for username, p := range t.players {
+		if p.Online && now.Sub(p.LastSeen) > t.timeout {
+			p.Online = false
+			t.players[username] = p
+			gone = append(gone, p)
+		}
+	}
+	sort.Slice(gone, func(i, j int) bool {
+		return gone[i].Username < gone[j].Username
+	})
+	return gone
+}
+
+func (t *Tracker) Get(username string) (Player, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.players[username]
+	return p, ok
+}
+
+// Players lists everyone seen so far, online players first.
+func (t *Tracker) Players() []Player {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	players := []Player{}
+	for _, p := range t.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Online != players[j].Online {
+			return players[i].Online
+		}
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
@@ -27,6 +27,28 @@ func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
 	)
 }
 
+// PublishJSONAs publishes val with its user-id set to userID, the broker user
+// ch's connection logged in as. The broker refuses the message if that's not
+// who published it, so subscribers can trust the sender.
+func PublishJSONAs[T any](ch *amqp.Channel, exchange, key, userID string, val T) error {
+	body, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return ch.PublishWithContext(
+		context.Background(),
+		exchange,
+		key,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			UserId:      userID,
+			Body:        body,
+		},
+	)
+}
+
 func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
@@ -48,6 +70,9 @@ func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
 	)
 }
 
+// DeadLetterExchange is where every queue's rejected messages go.
+const DeadLetterExchange = "peril_dlx"
+
 // go
 type SimpleQueueType int
 
@@ -75,7 +100,7 @@ func DeclareAndBind(
 		queueType == QueueTransient,
 		false,
 		amqp.Table{
-			"x-dead-letter-exchange": "peril_dlx",
+			"x-dead-letter-exchange": DeadLetterExchange,
 		},
 	)
 	if err != nil {
@@ -90,3 +115,13 @@ func DeclareAndBind(
 
 	return ch, q, nil
 }
+
+// AddBinding binds an already declared queue to another key.
+func AddBinding(conn *amqp.Connection, exchange, queueName, key string) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	return ch.QueueBind(queueName, key, exchange, false, nil)
+}
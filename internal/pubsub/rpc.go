@@ -0,0 +1,135 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// directReplyTo is RabbitMQ's pseudo queue for RPC replies, which avoids
+// declaring a reply queue per call.
+const directReplyTo = "amq.rabbitmq.reply-to"
+
+var ErrTimeout = errors.New("timed out waiting for reply")
+
+// CallJSON publishes req and waits for a single JSON reply from a ServeJSON
+// handler.
+func CallJSON[Req, Resp any](conn *amqp.Connection, exchange, key string, req Req, timeout time.Duration) (Resp, error) {
+	var zero Resp
+	ch, err := conn.Channel()
+	if err != nil {
+		return zero, err
+	}
+	defer ch.Close()
+
+	// must consume before publishing when using direct reply-to
+	replies, err := ch.Consume(directReplyTo, "", true, false, false, false, nil)
+	if err != nil {
+		return zero, fmt.Errorf("consume replies: %w", err)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return zero, err
+	}
+	corrID, err := newCorrelationID()
+	if err != nil {
+		return zero, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = ch.PublishWithContext(ctx, exchange, key, false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: corrID,
+		ReplyTo:       directReplyTo,
+		Body:          body,
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	for {
+		select {
+		case d, ok := <-replies:
+			if !ok {
+				return zero, errors.New("reply channel closed")
+			}
+			if d.CorrelationId != corrID {
+				continue
+			}
+			return unmarshalJSON[Resp](d.Body)
+		case <-ctx.Done():
+			return zero, ErrTimeout
+		}
+	}
+}
+
+// ServeJSON answers CallJSON requests bound to key with the handler's reply.
+func ServeJSON[Req, Resp any](
+	conn *amqp.Connection,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(Req) Resp,
+) error {
+	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, queueType)
+	if err != nil {
+		return fmt.Errorf("declare/bind: %w", err)
+	}
+
+	deliveries, err := ch.Consume(queue.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+
+	go func() {
+		defer ch.Close()
+		for d := range deliveries {
+			req, err := unmarshalJSON[Req](d.Body)
+			if err != nil {
+				fmt.Println("could not unmarshal request:", err)
+				_ = d.Nack(false, false)
+				continue
+			}
+			if d.ReplyTo == "" {
+				_ = d.Nack(false, false)
+				continue
+			}
+
+			body, err := json.Marshal(handler(req))
+			if err != nil {
+				fmt.Println("could not marshal reply:", err)
+				_ = d.Nack(false, false)
+				continue
+			}
+			err = ch.PublishWithContext(context.Background(), "", d.ReplyTo, false, false, amqp.Publishing{
+				ContentType:   "application/json",
+				CorrelationId: d.CorrelationId,
+				Body:          body,
+			})
+			if err != nil {
+				fmt.Println("could not publish reply:", err)
+				_ = d.Nack(false, true)
+				continue
+			}
+			_ = d.Ack(false)
+		}
+	}()
+
+	return nil
+}
+
+func newCorrelationID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,457 @@
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StompConn is a STOMP 1.2 connection to RabbitMQ's rabbitmq_stomp plugin.
+// It reaches the same exchanges, routing keys and queues as the AMQP
+// functions; docs/stomp.md lists the mapping for clients in other languages.
+type StompConn struct {
+	nc      net.Conn
+	r       *bufio.Reader
+	writeMu *sync.Mutex
+
+	mu     *sync.Mutex
+	subs   map[string]chan stompFrame
+	nextID int
+	err    error
+	done   chan struct{}
+}
+
+type stompFrame struct {
+	command string
+	headers map[string]string
+	body    []byte
+}
+
+var ErrStompClosed = errors.New("stomp connection closed")
+
+// stompPrefetch is how many unacknowledged messages the broker may send a
+// subscription, which is also how many it buffers.
+const stompPrefetch = 64
+
+// maxStompBody is the largest content-length a frame may declare, so a
+// broken or hostile peer can't make us allocate whatever it likes.
+const maxStompBody = 1 << 20
+
+// DialStomp connects and logs in to the broker at addr, usually port 61613.
+func DialStomp(addr, login, passcode, vhost string) (*StompConn, error) {
+	nc, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewStompConn(nc, login, passcode, vhost)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewStompConn logs in over an already open connection.
+func NewStompConn(nc net.Conn, login, passcode, vhost string) (*StompConn, error) {
+	c := &StompConn{
+		nc:      nc,
+		r:       bufio.NewReader(nc),
+		writeMu: &sync.Mutex{},
+		mu:      &sync.Mutex{},
+		subs:    map[string]chan stompFrame{},
+		done:    make(chan struct{}),
+	}
+	err := c.send(stompFrame{
+		command: "CONNECT",
+		headers: map[string]string{
+			"accept-version": "1.2",
+			"host":           vhost,
+			"login":          login,
+			"passcode":       passcode,
+			"heart-beat":     "0,0",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	f, err := readStompFrame(c.r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CONNECTED: %v", err)
+	}
+	if f.command == "ERROR" {
+		return nil, stompError(f)
+	}
+	if f.command != "CONNECTED" {
+		return nil, fmt.Errorf("expected CONNECTED, got %s", f.command)
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close disconnects and ends every subscription.
+func (c *StompConn) Close() error {
+	c.send(stompFrame{command: "DISCONNECT", headers: map[string]string{}})
+	err := c.nc.Close()
+	<-c.done
+	return err
+}
+
+// StompDestination is where a STOMP client sends to or subscribes to for an
+// exchange and routing key.
+func StompDestination(exchange, key string) string {
+	return fmt.Sprintf("/exchange/%s/%s", exchange, key)
+}
+
+// PublishStompJSON is PublishJSON over STOMP.
+func PublishStompJSON[T any](c *StompConn, exchange, key string, val T) error {
+	body, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.send(stompFrame{
+		command: "SEND",
+		headers: map[string]string{
+			"destination":  StompDestination(exchange, key),
+			"content-type": "application/json",
+		},
+		body: body,
+	})
+}
+
+// SubscribeStompJSON is SubscribeJSON over STOMP. The broker declares and
+// binds the queue from the subscription's headers.
+func SubscribeStompJSON[T any](
+	c *StompConn,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(T) Acktype,
+) error {
+	headers := map[string]string{
+		"destination":            StompDestination(exchange, key),
+		"ack":                    "client-individual",
+		"x-queue-name":           queueName,
+		"durable":                strconv.FormatBool(queueType == QueueDurable),
+		"auto-delete":            strconv.FormatBool(queueType == QueueTransient),
+		"exclusive":              strconv.FormatBool(queueType == QueueTransient),
+		"x-dead-letter-exchange": DeadLetterExchange,
+	}
+	_, frames, err := c.subscribe(headers)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for f := range frames {
+			msg, err := unmarshalJSON[T](f.body)
+			if err != nil {
+				fmt.Println("could not unmarshal message:", err)
+				_ = c.nack(f, false)
+				fmt.Println("NackDiscard (unmarshal error)")
+				continue
+			}
+
+			switch handler(msg) {
+			case Ack:
+				_ = c.ack(f)
+				fmt.Println("Ack")
+			case NackRequeue:
+				_ = c.nack(f, true)
+				fmt.Println("NackRequeue")
+			case NackDiscard:
+				_ = c.nack(f, false)
+				fmt.Println("NackDiscard")
+			}
+		}
+	}()
+	return nil
+}
+
+// CallStompJSON is CallJSON over STOMP. Replies come back on a temporary
+// queue the broker creates for the reply-to header.
+func CallStompJSON[Req, Resp any](c *StompConn, exchange, key string, req Req, timeout time.Duration) (Resp, error) {
+	var zero Resp
+	body, err := json.Marshal(req)
+	if err != nil {
+		return zero, err
+	}
+	corrID, err := newCorrelationID()
+	if err != nil {
+		return zero, err
+	}
+	replyTo := "/temp-queue/" + corrID
+	replies := c.expect(replyTo)
+	defer c.forget(replyTo)
+
+	err = c.send(stompFrame{
+		command: "SEND",
+		headers: map[string]string{
+			"destination":    StompDestination(exchange, key),
+			"content-type":   "application/json",
+			"correlation-id": corrID,
+			"reply-to":       replyTo,
+		},
+		body: body,
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case f, ok := <-replies:
+			if !ok {
+				return zero, c.closeErr()
+			}
+			if f.headers["correlation-id"] != corrID {
+				continue
+			}
+			return unmarshalJSON[Resp](f.body)
+		case <-timer.C:
+			return zero, ErrTimeout
+		}
+	}
+}
+
+func (c *StompConn) subscribe(headers map[string]string) (string, chan stompFrame, error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return "", nil, c.err
+	}
+	c.nextID++
+	id := fmt.Sprintf("sub-%d", c.nextID)
+	frames := make(chan stompFrame, stompPrefetch)
+	c.subs[id] = frames
+	c.mu.Unlock()
+
+	headers["id"] = id
+	headers["prefetch-count"] = strconv.Itoa(stompPrefetch)
+	if err := c.send(stompFrame{command: "SUBSCRIBE", headers: headers}); err != nil {
+		c.forget(id)
+		return "", nil, err
+	}
+	return id, frames, nil
+}
+
+// expect routes messages for a destination with no SUBSCRIBE of its own,
+// like a temporary reply queue.
+func (c *StompConn) expect(destination string) chan stompFrame {
+	frames := make(chan stompFrame, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs[destination] = frames
+	return frames
+}
+
+func (c *StompConn) forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if frames, ok := c.subs[id]; ok {
+		delete(c.subs, id)
+		close(frames)
+	}
+}
+
+func (c *StompConn) ack(f stompFrame) error {
+	return c.send(stompFrame{
+		command: "ACK",
+		headers: map[string]string{"id": f.headers["ack"]},
+	})
+}
+
+func (c *StompConn) nack(f stompFrame, requeue bool) error {
+	return c.send(stompFrame{
+		command: "NACK",
+		headers: map[string]string{
+			"id":      f.headers["ack"],
+			"requeue": strconv.FormatBool(requeue),
+		},
+	})
+}
+
+func (c *StompConn) readLoop() {
+	defer close(c.done)
+	for {
+		f, err := readStompFrame(c.r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch f.command {
+		case "MESSAGE":
+			if !c.deliver(f) && f.headers["ack"] != "" {
+				// the broker keeps at most stompPrefetch unacked messages per
+				// subscription, so this only happens to a misbehaving one;
+				// give the message back rather than lose it
+				_ = c.nack(f, true)
+			}
+		case "ERROR":
+			c.fail(stompError(f))
+			c.nc.Close()
+			return
+		}
+	}
+}
+
+// deliver hands f to its subscription without blocking, and reports false
+// when nobody wants it or the subscription's buffer is full. It never waits
+// while holding the lock, so a slow handler can't stop forget, subscribe or
+// another subscription's deliveries.
+func (c *StompConn) deliver(f stompFrame) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames, ok := c.subs[f.headers["subscription"]]
+	if !ok {
+		frames, ok = c.subs[f.headers["destination"]]
+	}
+	if !ok {
+		return false
+	}
+	select {
+	case frames <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+// fail ends every subscription after the connection is lost.
+func (c *StompConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		err = ErrStompClosed
+	}
+	c.err = err
+	for id, frames := range c.subs {
+		delete(c.subs, id)
+		close(frames)
+	}
+}
+
+func (c *StompConn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return ErrStompClosed
+	}
+	return c.err
+}
+
+func (c *StompConn) send(f stompFrame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.nc.Write(f.encode())
+	return err
+}
+
+func stompError(f stompFrame) error {
+	msg := f.headers["message"]
+	if len(f.body) > 0 {
+		msg += ": " + strings.TrimSpace(string(f.body))
+	}
+	return fmt.Errorf("stomp error: %s", msg)
+}
+
+// encode writes the frame as STOMP 1.2. CONNECT headers are sent as is,
+// every other frame's are escaped.
+func (f stompFrame) encode() []byte {
+	var b bytes.Buffer
+	b.WriteString(f.command + "\n")
+	escape := f.command != "CONNECT"
+	for k, v := range f.headers {
+		if escape {
+			k, v = escapeStompHeader(k), escapeStompHeader(v)
+		}
+		b.WriteString(k + ":" + v + "\n")
+	}
+	if len(f.body) > 0 {
+		b.WriteString("content-length:" + strconv.Itoa(len(f.body)) + "\n")
+	}
+	b.WriteString("\n")
+	b.Write(f.body)
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+func readStompFrame(r *bufio.Reader) (stompFrame, error) {
+	f := stompFrame{headers: map[string]string{}}
+	// blank lines between frames are heart-beats
+	for f.command == "" {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return stompFrame{}, err
+		}
+		f.command = strings.TrimRight(line, "\r\n")
+	}
+	escaped := f.command != "CONNECTED"
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return stompFrame{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return stompFrame{}, fmt.Errorf("malformed stomp header %q", line)
+		}
+		if escaped {
+			k, v = unescapeStompHeader(k), unescapeStompHeader(v)
+		}
+		// the first of a repeated header is the one that counts
+		if _, ok := f.headers[k]; !ok {
+			f.headers[k] = v
+		}
+	}
+
+	if n, err := strconv.Atoi(f.headers["content-length"]); err == nil {
+		if n < 0 || n > maxStompBody {
+			return stompFrame{}, fmt.Errorf("stomp content-length %d is out of range", n)
+		}
+		f.body = make([]byte, n)
+		if _, err := io.ReadFull(r, f.body); err != nil {
+			return stompFrame{}, err
+		}
+		if b, err := r.ReadByte(); err != nil || b != 0 {
+			return stompFrame{}, errors.New("stomp frame body is not NUL terminated")
+		}
+		return f, nil
+	}
+	body, err := r.ReadBytes(0)
+	if err != nil {
+		return stompFrame{}, err
+	}
+	f.body = body[:len(body)-1]
+	return f, nil
+}
+
+var (
+	stompEscaper   = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+	stompUnescaper = strings.NewReplacer("\\\\", "\\", "\\r", "\r", "\\n", "\n", "\\c", ":")
+)
+
+func escapeStompHeader(s string) string {
+	return stompEscaper.Replace(s)
+}
+
+func unescapeStompHeader(s string) string {
+	return stompUnescaper.Replace(s)
+}
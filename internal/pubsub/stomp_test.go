@@ -0,0 +1,439 @@
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStomp is the broker's end of a net.Pipe. It runs on the test goroutine,
+// so anything the client does that writes to the pipe has to run in its own
+// goroutine until the broker reads it.
+type fakeStomp struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func (b *fakeStomp) read() stompFrame {
+	b.t.Helper()
+	f, err := readStompFrame(b.r)
+	if err != nil {
+		b.t.Fatalf("broker could not read a frame: %v", err)
+	}
+	return f
+}
+
+func (b *fakeStomp) expect(command string) stompFrame {
+	b.t.Helper()
+	f := b.read()
+	if f.command != command {
+		b.t.Fatalf("broker got %s, want %s", f.command, command)
+	}
+	return f
+}
+
+func (b *fakeStomp) write(f stompFrame) {
+	b.t.Helper()
+	if _, err := b.nc.Write(f.encode()); err != nil {
+		b.t.Fatalf("broker could not write %s: %v", f.command, err)
+	}
+}
+
+// dialFakeStomp connects a StompConn to a fake broker that accepts the login.
+func dialFakeStomp(t *testing.T) (*StompConn, *fakeStomp) {
+	t.Helper()
+	client, server := net.Pipe()
+	deadline := time.Now().Add(5 * time.Second)
+	client.SetDeadline(deadline)
+	server.SetDeadline(deadline)
+	b := &fakeStomp{t: t, nc: server, r: bufio.NewReader(server)}
+
+	type result struct {
+		c   *StompConn
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := NewStompConn(client, "alice", "secret:pass", "/")
+		done <- result{c, err}
+	}()
+
+	f := b.expect("CONNECT")
+	want := map[string]string{
+		"accept-version": "1.2",
+		"host":           "/",
+		"login":          "alice",
+		// CONNECT headers aren't escaped, so a colon arrives as is
+		"passcode": "secret:pass",
+	}
+	for k, v := range want {
+		if f.headers[k] != v {
+			t.Errorf("CONNECT %s = %q, want %q", k, f.headers[k], v)
+		}
+	}
+	b.write(stompFrame{command: "CONNECTED", headers: map[string]string{"version": "1.2"}})
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("NewStompConn: %v", res.err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		res.c.Close()
+	})
+	return res.c, b
+}
+
+func TestStompConnectError(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	b := &fakeStomp{t: t, nc: server, r: bufio.NewReader(server)}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewStompConn(client, "alice", "wrong", "/")
+		done <- err
+	}()
+	b.expect("CONNECT")
+	b.write(stompFrame{
+		command: "ERROR",
+		headers: map[string]string{"message": "access refused"},
+		body:    []byte("bad login\n"),
+	})
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "access refused: bad login") {
+		t.Fatalf("NewStompConn error = %v, want the broker's message", err)
+	}
+}
+
+func TestPublishStompJSON(t *testing.T) {
+	c, b := dialFakeStomp(t)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- PublishStompJSON(c, "peril_topic", "army_moves.g1.alice", map[string]int{"Units": 2})
+	}()
+	f := b.expect("SEND")
+	if err := <-done; err != nil {
+		t.Fatalf("PublishStompJSON: %v", err)
+	}
+	if got, want := f.headers["destination"], "/exchange/peril_topic/army_moves.g1.alice"; got != want {
+		t.Errorf("destination = %q, want %q", got, want)
+	}
+	if got := f.headers["content-type"]; got != "application/json" {
+		t.Errorf("content-type = %q", got)
+	}
+	if got, want := f.headers["content-length"], fmt.Sprint(len(f.body)); got != want {
+		t.Errorf("content-length = %q, want %q", got, want)
+	}
+	if string(f.body) != `{"Units":2}` {
+		t.Errorf("body = %q", f.body)
+	}
+}
+
+func TestSubscribeStompJSONAcks(t *testing.T) {
+	c, b := dialFakeStomp(t)
+
+	type move struct{ Result string }
+	handler := func(m move) Acktype {
+		switch m.Result {
+		case "requeue":
+			return NackRequeue
+		case "discard":
+			return NackDiscard
+		}
+		return Ack
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- SubscribeStompJSON(c, "peril_topic", "army_moves.g1.alice", "army_moves.g1.*", QueueTransient, handler)
+	}()
+	sub := b.expect("SUBSCRIBE")
+	if err := <-done; err != nil {
+		t.Fatalf("SubscribeStompJSON: %v", err)
+	}
+	want := map[string]string{
+		"destination":  "/exchange/peril_topic/army_moves.g1.*",
+		"ack":          "client-individual",
+		"x-queue-name": "army_moves.g1.alice",
+		"durable":      "false",
+		"auto-delete":  "true",
+		"exclusive":    "true",
+	}
+	for k, v := range want {
+		if sub.headers[k] != v {
+			t.Errorf("SUBSCRIBE %s = %q, want %q", k, sub.headers[k], v)
+		}
+	}
+
+	tests := []struct {
+		body    string
+		command string
+		requeue string
+	}{
+		{`{"Result":"ok"}`, "ACK", ""},
+		{`{"Result":"requeue"}`, "NACK", "true"},
+		{`{"Result":"discard"}`, "NACK", "false"},
+		{`not json`, "NACK", "false"},
+	}
+	for i, tt := range tests {
+		ackID := fmt.Sprintf("m-%d", i)
+		b.write(stompFrame{
+			command: "MESSAGE",
+			headers: map[string]string{
+				"subscription": sub.headers["id"],
+				"destination":  sub.headers["destination"],
+				"ack":          ackID,
+			},
+			body: []byte(tt.body),
+		})
+		f := b.expect(tt.command)
+		if f.headers["id"] != ackID {
+			t.Errorf("%s for %s has id %q", tt.command, tt.body, f.headers["id"])
+		}
+		if f.headers["requeue"] != tt.requeue {
+			t.Errorf("%s for %s has requeue %q, want %q", tt.command, tt.body, f.headers["requeue"], tt.requeue)
+		}
+	}
+}
+
+// A handler that never returns must not stop the connection: the message the
+// subscription can't buffer goes back to the broker and RPCs keep working.
+func TestStompSlowSubscriber(t *testing.T) {
+	c, b := dialFakeStomp(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var first bool
+	handler := func(struct{}) Acktype {
+		if !first {
+			first = true
+			close(started)
+			<-release
+		}
+		return Ack
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- SubscribeStompJSON(c, "peril_topic", "q", "k", QueueDurable, handler)
+	}()
+	sub := b.expect("SUBSCRIBE")
+	if err := <-done; err != nil {
+		t.Fatalf("SubscribeStompJSON: %v", err)
+	}
+	if got, want := sub.headers["prefetch-count"], fmt.Sprint(stompPrefetch); got != want {
+		t.Errorf("prefetch-count = %q, want %q", got, want)
+	}
+	message := func(i int) {
+		b.write(stompFrame{
+			command: "MESSAGE",
+			headers: map[string]string{"subscription": sub.headers["id"], "ack": fmt.Sprintf("m-%d", i)},
+			body:    []byte("{}"),
+		})
+	}
+
+	message(0)
+	<-started
+	// one in the handler, stompPrefetch buffered and one too many
+	for i := 1; i <= stompPrefetch+1; i++ {
+		message(i)
+	}
+	f := b.expect("NACK")
+	if f.headers["id"] != fmt.Sprintf("m-%d", stompPrefetch+1) || f.headers["requeue"] != "true" {
+		t.Fatalf("overflow NACK has id %q and requeue %q", f.headers["id"], f.headers["requeue"])
+	}
+
+	calls := make(chan error, 1)
+	go func() {
+		_, err := CallStompJSON[struct{}, struct{}](c, "peril_direct", "register", struct{}{}, time.Second)
+		calls <- err
+	}()
+	send := b.expect("SEND")
+	b.write(stompFrame{
+		command: "MESSAGE",
+		headers: map[string]string{
+			"destination":    send.headers["reply-to"],
+			"correlation-id": send.headers["correlation-id"],
+		},
+		body: []byte("{}"),
+	})
+	if err := <-calls; err != nil {
+		t.Fatalf("CallStompJSON behind a slow subscriber: %v", err)
+	}
+
+	close(release)
+	for i := 0; i <= stompPrefetch; i++ {
+		if f := b.expect("ACK"); f.headers["id"] != fmt.Sprintf("m-%d", i) {
+			t.Fatalf("ACK %d has id %q", i, f.headers["id"])
+		}
+	}
+}
+
+func TestCallStompJSON(t *testing.T) {
+	c, b := dialFakeStomp(t)
+
+	type request struct{ Username string }
+	type response struct{ Accepted bool }
+	type result struct {
+		resp response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := CallStompJSON[request, response](c, "peril_direct", "register", request{"alice"}, time.Second)
+		done <- result{resp, err}
+	}()
+
+	f := b.expect("SEND")
+	replyTo := f.headers["reply-to"]
+	corrID := f.headers["correlation-id"]
+	if !strings.HasPrefix(replyTo, "/temp-queue/") {
+		t.Fatalf("reply-to = %q, want a temporary queue", replyTo)
+	}
+	if corrID == "" {
+		t.Fatal("request has no correlation-id")
+	}
+	if got := f.headers["destination"]; got != "/exchange/peril_direct/register" {
+		t.Errorf("destination = %q", got)
+	}
+	var req request
+	if err := json.Unmarshal(f.body, &req); err != nil || req.Username != "alice" {
+		t.Errorf("request body = %q", f.body)
+	}
+
+	// replies to a temporary queue come with no subscription of their own
+	b.write(stompFrame{
+		command: "MESSAGE",
+		headers: map[string]string{
+			"destination":    replyTo,
+			"correlation-id": corrID,
+		},
+		body: []byte(`{"Accepted":true}`),
+	})
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("CallStompJSON: %v", res.err)
+	}
+	if !res.resp.Accepted {
+		t.Errorf("response = %+v", res.resp)
+	}
+
+	// a late duplicate for the finished call is ignored
+	b.write(stompFrame{
+		command: "MESSAGE",
+		headers: map[string]string{"destination": replyTo, "correlation-id": corrID},
+		body:    []byte(`{"Accepted":false}`),
+	})
+	go PublishStompJSON(c, "peril_topic", "k", struct{}{})
+	b.expect("SEND")
+}
+
+func TestCallStompJSONTimeout(t *testing.T) {
+	c, b := dialFakeStomp(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := CallStompJSON[struct{}, struct{}](c, "peril_direct", "register", struct{}{}, 10*time.Millisecond)
+		done <- err
+	}()
+	b.expect("SEND")
+	if err := <-done; !errors.Is(err, ErrTimeout) {
+		t.Fatalf("CallStompJSON error = %v, want ErrTimeout", err)
+	}
+}
+
+func TestStompHeaderEscaping(t *testing.T) {
+	headers := map[string]string{
+		"destination": "/exchange/peril_topic/chat.direct.bob",
+		"note":        "a:b\nc\\d\re",
+		"x:key":       "value",
+	}
+	raw := stompFrame{command: "SEND", headers: headers}.encode()
+	if !bytes.Contains(raw, []byte(`note:a\cb\nc\\d\re`+"\n")) {
+		t.Errorf("note was not escaped:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte(`x\ckey:value`+"\n")) {
+		t.Errorf("header name was not escaped:\n%s", raw)
+	}
+
+	f, err := readStompFrame(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("readStompFrame: %v", err)
+	}
+	for k, v := range headers {
+		if f.headers[k] != v {
+			t.Errorf("header %q = %q, want %q", k, f.headers[k], v)
+		}
+	}
+
+	// CONNECTED is read as is, as STOMP 1.2 requires
+	f, err = readStompFrame(bufio.NewReader(strings.NewReader("CONNECTED\nserver:RabbitMQ/3\\c13\n\n\x00")))
+	if err != nil {
+		t.Fatalf("readStompFrame: %v", err)
+	}
+	if got := f.headers["server"]; got != `RabbitMQ/3\c13` {
+		t.Errorf("CONNECTED server = %q, want it unescaped", got)
+	}
+}
+
+func TestReadStompFrameBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		body string
+		err  bool
+	}{
+		{"content-length", "MESSAGE\ncontent-length:3\n\na\x00b\x00", "a\x00b", false},
+		{"up to NUL", "MESSAGE\n\nhello\x00", "hello", false},
+		{"heart-beats first", "\n\r\nMESSAGE\n\nhi\x00", "hi", false},
+		{"CRLF lines", "MESSAGE\r\ncontent-length:2\r\n\r\nhi\x00", "hi", false},
+		{"repeated header", "MESSAGE\ncontent-length:2\ncontent-length:5\n\nhi\x00", "hi", false},
+		{"no NUL after content-length", "MESSAGE\ncontent-length:2\n\nhix", "", true},
+		{"short body", "MESSAGE\ncontent-length:9\n\nhi\x00", "", true},
+		{"malformed header", "MESSAGE\nnocolon\n\n\x00", "", true},
+		{"negative content-length", "MESSAGE\ncontent-length:-1\n\n\x00", "", true},
+		{"huge content-length", "MESSAGE\ncontent-length:9999999999\n\n\x00", "", true},
+		{"content-length just too large", "MESSAGE\ncontent-length:" + strconv.Itoa(maxStompBody+1) + "\n\n\x00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := readStompFrame(bufio.NewReader(strings.NewReader(tt.raw)))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("readStompFrame succeeded with body %q", f.body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readStompFrame: %v", err)
+			}
+			if f.command != "MESSAGE" {
+				t.Errorf("command = %q", f.command)
+			}
+			if string(f.body) != tt.body {
+				t.Errorf("body = %q, want %q", f.body, tt.body)
+			}
+		})
+	}
+}
+
+func TestStompEncodeContentLength(t *testing.T) {
+	body := []byte("a\x00b")
+	raw := stompFrame{command: "SEND", headers: map[string]string{}, body: body}.encode()
+	f, err := readStompFrame(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("readStompFrame: %v", err)
+	}
+	if !bytes.Equal(f.body, body) {
+		t.Errorf("body = %q, want %q", f.body, body)
+	}
+}
@@ -24,6 +24,20 @@ func SubscribeJSON[T any](
 	key string,
 	queueType SimpleQueueType,
 	handler func(T) Acktype,
+) error {
+	return subscribe(conn, exchange, queueName, key, queueType, ignoreSender(handler), unmarshalJSON[T])
+}
+
+// SubscribeJSONFrom is SubscribeJSON for a handler that needs to know who
+// sent each message: the broker user it was published as, which the broker
+// checks, or "" if the publisher didn't say.
+func SubscribeJSONFrom[T any](
+	conn *amqp.Connection,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	handler func(msg T, sender string) Acktype,
 ) error {
 	return subscribe(conn, exchange, queueName, key, queueType, handler, unmarshalJSON[T])
 }
@@ -42,7 +56,13 @@ func SubscribeGob[T any](
 	queueType SimpleQueueType,
 	handler func(T) Acktype,
 ) error {
-	return subscribe(conn, exchange, queueName, key, queueType, handler, unmarshalGob[T])
+	return subscribe(conn, exchange, queueName, key, queueType, ignoreSender(handler), unmarshalGob[T])
+}
+
+func ignoreSender[T any](handler func(T) Acktype) func(T, string) Acktype {
+	return func(msg T, _ string) Acktype {
+		return handler(msg)
+	}
 }
 
 func unmarshalGob[T any](data []byte) (T, error) {
@@ -62,7 +82,7 @@ func subscribe[T any](
 	queueName,
 	key string,
 	queueType SimpleQueueType,
-	handler func(T) Acktype,
+	handler func(T, string) Acktype,
 	unmarshaller func([]byte) (T, error),
 ) error {
 	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, queueType)
@@ -86,7 +106,7 @@ func subscribe[T any](
 				continue
 			}
 
-			ackType := handler(msg)
+			ackType := handler(msg, d.UserId)
 
 			switch ackType {
 			case Ack:
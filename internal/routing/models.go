@@ -4,10 +4,183 @@ import "time"
 
 type PlayingState struct {
 	IsPaused bool
+	// Reason is shown to paused players.
+	Reason string
+	// ResumeAt is when a pause lifts on its own; zero means until resumed.
+	ResumeAt time.Time
+	// Target limits the message to one player; empty means everyone who
+	// receives it.
+	Target string
+	// GameID scopes the message to one game. Roster and the turn settings
+	// are only set on the message that starts a game.
+	GameID     string
+	Roster     []string
+	TurnMode   bool
+	TurnLength time.Duration
+	OrderLimit int
 }
 
+const (
+	LogTypeMessage = "message"
+	LogTypeWar     = "war"
+	LogTypeChat    = "chat"
+)
+
+const (
+	LogSeverityInfo  = "info"
+	LogSeverityWarn  = "warn"
+	LogSeverityError = "error"
+)
+
+// GameLog travels as gob, so the JSON keys only matter in the log file, where
+// they are snake_case like every file the game writes.
 type GameLog struct {
-	CurrentTime time.Time
-	Message     string
-	Username    string
+	CurrentTime time.Time `json:"time"`
+	Message     string    `json:"message"`
+	Username    string    `json:"username"`
+	// Older clients don't send the fields below. Gob leaves missing fields
+	// empty, and Normalize fills in what it can.
+	GameID   string `json:"game_id,omitempty"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	// Fields holds the details of the event, e.g. a war's winner, so tools
+	// can query logs without parsing the message.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Normalize gives logs from older clients a type and severity.
+func (l *GameLog) Normalize() {
+	if l.Type == "" {
+		l.Type = LogTypeMessage
+	}
+	if l.Severity == "" {
+		l.Severity = LogSeverityInfo
+	}
+}
+
+const (
+	LobbyActionAnnounce  = "announce"
+	LobbyActionList      = "list"
+	LobbyActionCreate    = "create"
+	LobbyActionJoin      = "join"
+	LobbyActionAutoMatch = "automatch"
+	LobbyActionReady     = "ready"
+	LobbyActionLeave     = "leave"
+)
+
+type LobbyRequest struct {
+	Username string
+	Token    string
+	Action   string
+	GameID   string
+	Size     int
+}
+
+type GameSummary struct {
+	GameID  string
+	Host    string
+	Size    int
+	Players []string
+	Ready   []string
+	Started bool
+}
+
+const (
+	LobbyEventGames      = "games"
+	LobbyEventJoined     = "joined"
+	LobbyEventLeft       = "left"
+	LobbyEventReadyCheck = "ready_check"
+	LobbyEventStarted    = "started"
+	LobbyEventError      = "error"
+)
+
+type LobbyUpdate struct {
+	Event string
+	Game  GameSummary
+	Games []GameSummary
+	Error string
+}
+
+type RegisterRequest struct {
+	Username string
+	// ConnectionName is the client's AMQP connection name, so admins can
+	// close the connection of a kicked player.
+	ConnectionName string
+	// Spectator registers a name that can watch games but never play.
+	Spectator bool
+}
+
+type RegisterResponse struct {
+	Token             string
+	HeartbeatInterval time.Duration
+	// BrokerUser and BrokerPassword log a player in as their own broker
+	// user for the rest of the session. Spectators get neither.
+	BrokerUser     string
+	BrokerPassword string
+	Error          string
+}
+
+type Heartbeat struct {
+	Username string
+	Token    string
+	GameID   string
+	SentAt   time.Time
+	// Leaving is set on the last heartbeat of a client that quits cleanly.
+	Leaving bool
+}
+
+type PresenceEvent struct {
+	Username string
+	GameID   string
+	Online   bool
+	At       time.Time
+}
+
+type Announcement struct {
+	Message string
+	At      time.Time
+}
+
+const (
+	AdminActionKick = "kick"
+	AdminActionBan  = "ban"
+)
+
+type AdminNotice struct {
+	Action string
+	Reason string
+}
+
+const (
+	DiplomacyPropose = "propose"
+	DiplomacyAccept  = "accept"
+	DiplomacyBreak   = "break"
+)
+
+type Diplomacy struct {
+	GameID string
+	From   string
+	To     string
+	Action string
+	At     time.Time
+}
+
+const (
+	ChatChannelGlobal   = "global"
+	ChatChannelGame     = "game"
+	ChatChannelAlliance = "alliance"
+	ChatChannelDirect   = "direct"
+)
+
+// ChatMessage is sent to the server as a request, which checks it and
+// delivers it without the token to every recipient.
+type ChatMessage struct {
+	Username string
+	Token    string
+	GameID   string
+	Channel  string
+	// To lists the recipients of alliance and direct messages.
+	To   []string
+	Text string
+	At   time.Time
 }
@@ -1,16 +1,396 @@
 package routing
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
 const (
 	ArmyMovesPrefix = "army_moves"
 
 	WarRecognitionsPrefix = "war"
 
+	WarDeclarationsPrefix = "war_declarations"
+
 	PauseKey = "pause"
 
 	GameLogSlug = "game_logs"
+
+	LobbyPrefix = "lobby"
+
+	LobbyUpdatesPrefix = "lobby_updates"
+
+	RegisterKey = "register"
+
+	PresencePrefix = "presence"
+
+	PresenceEventsPrefix = "presence_events"
+
+	AnnouncementsPrefix = "announcements"
+
+	AdminPrefix = "admin"
+
+	TurnsPrefix = "turns"
+
+	OrdersPrefix = "orders"
+
+	EconomyPrefix = "economy"
+
+	ResourcesPrefix = "resources"
+
+	GameOverPrefix = "game_over"
+
+	DiplomacyPrefix = "diplomacy"
+
+	ChatRequestsPrefix = "chat_requests"
+
+	ChatPrefix = "chat"
+
+	VisibleMovesPrefix = "visible_moves"
+
+	ArmyReportsPrefix = "army_reports"
+
+	SpectateKey = "spectate"
+
+	WarResultsPrefix = "war_results"
+
+	LeaderboardKey = "leaderboard"
+
+	SpawnsPrefix = "spawns"
 )
 
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
+	// ExchangePerilServer is a topic exchange for the messages that carry a
+	// player's whole army. Players may publish to it, but only the server
+	// and spectators may read from it, so nobody can see past the fog.
+	ExchangePerilServer = "peril_server"
+	// ExchangePerilRPC is a direct exchange for requests the server answers,
+	// like registering. Spectators may publish to it, but not to
+	// peril_direct, where the server's pauses go.
+	ExchangePerilRPC = "peril_rpc"
+)
+
+// SpectatorUser is the broker user spectators connect as. The server limits
+// what it may publish.
+const SpectatorUser = "peril_spectator"
+
+// PlayerUser is the broker user players connect as to register, and
+// PlayerPassword its password, which is no secret: the user can do nothing
+// but register. Registering gives each player a broker user of their own,
+// named by PlayerBrokerUser, which the server limits to that player's
+// traffic.
+const (
+	PlayerUser     = "peril_player"
+	PlayerPassword = "peril_player"
 )
+
+func PlayerBrokerUser(username string) string {
+	return PlayerUser + "." + username
+}
+
+// ServerUserPrefix starts the name of the broker user each server creates
+// for itself.
+const ServerUserPrefix = "peril_server"
+
+// IsServerUser reports whether a message's sender is a server's own broker
+// user, which players can't log in as.
+func IsServerUser(name string) bool {
+	return strings.HasPrefix(name, ServerUserPrefix+".")
+}
+
+// Every game's keys and queues are scoped by its game ID so several games can
+// share one broker without seeing each other's traffic.
+
+func NewGameID() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func ValidateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("username must not be empty")
+	}
+	if strings.ContainsAny(username, ".*# ") {
+		return fmt.Errorf("username %q must not contain '.', '*', '#' or spaces", username)
+	}
+	return nil
+}
+
+func ValidateGameID(gameID string) error {
+	if gameID == "" {
+		return fmt.Errorf("game ID must not be empty")
+	}
+	if strings.ContainsAny(gameID, ".*# ") {
+		return fmt.Errorf("game ID %q must not contain '.', '*', '#' or spaces", gameID)
+	}
+	return nil
+}
+
+func ArmyMovesKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", ArmyMovesPrefix, gameID, username)
+}
+
+func ArmyMovesQueue(gameID, username string) string {
+	return ArmyMovesKey(gameID, username)
+}
+
+// AllArmyMovesBinding lets the server watch the moves of every game.
+func AllArmyMovesBinding() string {
+	return ArmyMovesPrefix + ".#"
+}
+
+// ArmyMovesBinding matches the unfiltered moves of every player in a game.
+func ArmyMovesBinding(gameID string) string {
+	return fmt.Sprintf("%s.%s.*", ArmyMovesPrefix, gameID)
+}
+
+// ServerQueue names the server's queue for a prefix on peril_server. Players
+// can't read from or delete queues named like this.
+func ServerQueue(prefix string) string {
+	return "server." + prefix
+}
+
+func ServerArmyMovesQueue() string {
+	return ServerQueue(ArmyMovesPrefix)
+}
+
+func WarRecognitionsKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", WarRecognitionsPrefix, gameID, username)
+}
+
+func WarRecognitionsBinding(gameID string) string {
+	return fmt.Sprintf("%s.%s.*", WarRecognitionsPrefix, gameID)
+}
+
+// WarRecognitionsQueue is durable and shared by every client in the game.
+func WarRecognitionsQueue(gameID string) string {
+	return fmt.Sprintf("%s.%s", WarRecognitionsPrefix, gameID)
+}
+
+// WarDeclarationKey is where a defender declares a war. The server adds the
+// defender's allies and publishes it on WarRecognitionsKey.
+func WarDeclarationKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", WarDeclarationsPrefix, gameID, username)
+}
+
+func WarDeclarationBinding() string {
+	return WarDeclarationsPrefix + ".#"
+}
+
+func WarResultKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", WarResultsPrefix, gameID, username)
+}
+
+func WarResultBinding() string {
+	return WarResultsPrefix + ".#"
+}
+
+func PauseGameKey(gameID string) string {
+	return fmt.Sprintf("%s.%s", PauseKey, gameID)
+}
+
+func PauseQueue(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", PauseKey, gameID, username)
+}
+
+func GameLogKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", GameLogSlug, gameID, username)
+}
+
+// GameLogBinding matches logs from every game, including the unscoped
+// game_logs.<username> keys published by older clients.
+func GameLogBinding() string {
+	return GameLogSlug + ".#"
+}
+
+func GameLogGameBinding(gameID string) string {
+	return fmt.Sprintf("%s.%s.*", GameLogSlug, gameID)
+}
+
+func LobbyKey(username string) string {
+	return fmt.Sprintf("%s.%s", LobbyPrefix, username)
+}
+
+func LobbyBinding() string {
+	return LobbyPrefix + ".*"
+}
+
+func LobbyUpdatesKey(username string) string {
+	return fmt.Sprintf("%s.%s", LobbyUpdatesPrefix, username)
+}
+
+func HeartbeatKey(username string) string {
+	return fmt.Sprintf("%s.%s", PresencePrefix, username)
+}
+
+func HeartbeatBinding() string {
+	return PresencePrefix + ".*"
+}
+
+func PresenceEventKey(username string) string {
+	return fmt.Sprintf("%s.%s", PresenceEventsPrefix, username)
+}
+
+func PresenceEventBinding() string {
+	return PresenceEventsPrefix + ".*"
+}
+
+func AnnouncementsKey() string {
+	return AnnouncementsPrefix + ".all"
+}
+
+func AnnouncementsQueue(username string) string {
+	return fmt.Sprintf("%s.%s", AnnouncementsPrefix, username)
+}
+
+func AdminKey(username string) string {
+	return fmt.Sprintf("%s.%s", AdminPrefix, username)
+}
+
+func TurnsKey(gameID string) string {
+	return fmt.Sprintf("%s.%s", TurnsPrefix, gameID)
+}
+
+func TurnsQueue(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", TurnsPrefix, gameID, username)
+}
+
+func OrdersKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", OrdersPrefix, gameID, username)
+}
+
+func OrdersBinding() string {
+	return OrdersPrefix + ".#"
+}
+
+func IncomeKey(gameID string) string {
+	return fmt.Sprintf("%s.%s", EconomyPrefix, gameID)
+}
+
+func IncomeQueue(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", EconomyPrefix, gameID, username)
+}
+
+func ResourceReportKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", ResourcesPrefix, gameID, username)
+}
+
+func ResourceReportBinding() string {
+	return ResourcesPrefix + ".#"
+}
+
+func SpawnKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", SpawnsPrefix, gameID, username)
+}
+
+func SpawnBinding() string {
+	return SpawnsPrefix + ".#"
+}
+
+func GameOverKey(gameID string) string {
+	return fmt.Sprintf("%s.%s", GameOverPrefix, gameID)
+}
+
+func GameOverQueue(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", GameOverPrefix, gameID, username)
+}
+
+func DiplomacyKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", DiplomacyPrefix, gameID, username)
+}
+
+// DiplomacyBinding matches every diplomacy message, which the server follows
+// to keep its own record of alliances.
+func DiplomacyBinding() string {
+	return DiplomacyPrefix + ".#"
+}
+
+func ChatRequestKey(username string) string {
+	return fmt.Sprintf("%s.%s", ChatRequestsPrefix, username)
+}
+
+func ChatRequestBinding() string {
+	return ChatRequestsPrefix + ".*"
+}
+
+func ChatGlobalKey() string {
+	return fmt.Sprintf("%s.%s", ChatPrefix, ChatChannelGlobal)
+}
+
+func ChatGameKey(gameID string) string {
+	return fmt.Sprintf("%s.%s.%s", ChatPrefix, ChatChannelGame, gameID)
+}
+
+func ChatAllianceKey(username string) string {
+	return fmt.Sprintf("%s.%s.%s", ChatPrefix, ChatChannelAlliance, username)
+}
+
+func ChatDirectKey(username string) string {
+	return fmt.Sprintf("%s.%s.%s", ChatPrefix, ChatChannelDirect, username)
+}
+
+func ChatQueue(username string) string {
+	return fmt.Sprintf("%s.%s", ChatPrefix, username)
+}
+
+func VisibleMovesKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", VisibleMovesPrefix, gameID, username)
+}
+
+func ArmyReportKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", ArmyReportsPrefix, gameID, username)
+}
+
+func ArmyReportBinding() string {
+	return ArmyReportsPrefix + ".#"
+}
+
+func ArmyReportGameBinding(gameID string) string {
+	return fmt.Sprintf("%s.%s.*", ArmyReportsPrefix, gameID)
+}
+
+// SpectatorQueue is a spectator's own copy of one kind of a game's traffic,
+// so watching never takes messages from the players' queues.
+func SpectatorQueue(prefix, gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", SpectateKey, prefix, gameID, username)
+}
+
+// TurnsPlayerKey carries the end of a turn as one player sees it.
+func TurnsPlayerKey(gameID, username string) string {
+	return fmt.Sprintf("%s.%s.%s", TurnsPrefix, gameID, username)
+}
+
+// PlayerQueues names every queue a player's client declares for itself, so
+// they can be removed without touching anyone else's. gameID may be empty
+// for a player who is not in a game.
+func PlayerQueues(gameID, username string) []string {
+	queues := []string{
+		PresenceEventKey(username),
+		AnnouncementsQueue(username),
+		AdminKey(username),
+		LobbyUpdatesKey(username),
+		ChatQueue(username),
+	}
+	if gameID == "" {
+		return queues
+	}
+	queues = append(queues,
+		ArmyMovesQueue(gameID, username),
+		IncomeQueue(gameID, username),
+		GameOverQueue(gameID, username),
+		DiplomacyKey(gameID, username),
+		TurnsQueue(gameID, username),
+		PauseQueue(gameID, username),
+	)
+	for _, prefix := range []string{ArmyMovesPrefix, ArmyReportsPrefix, WarRecognitionsPrefix, GameLogSlug, GameOverPrefix, PauseKey} {
+		queues = append(queues, SpectatorQueue(prefix, gameID, username))
+	}
+	return queues
+}
@@ -0,0 +1,222 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+)
+
+// topicMatches reports whether a topic exchange delivers key to a queue bound
+// with binding: '*' matches exactly one word and '#' matches zero or more.
+func topicMatches(binding, key string) bool {
+	return matchWords(strings.Split(binding, "."), strings.Split(key, "."))
+}
+
+func matchWords(binding, key []string) bool {
+	if len(binding) == 0 {
+		return len(key) == 0
+	}
+	switch binding[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(binding[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(binding[1:], key[1:])
+	default:
+		return len(key) > 0 && binding[0] == key[0] && matchWords(binding[1:], key[1:])
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		binding string
+		key     string
+		want    bool
+	}{
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"a.#", "a", true},
+		{"a.#", "a.b.c", true},
+		{"a.*.c", "a.b.c", true},
+		{"a.*.c", "a.b.d", false},
+		{"#", "a.b", true},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.binding, tt.key); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.binding, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewGameID(t *testing.T) {
+	a, b := NewGameID(), NewGameID()
+	if a == b {
+		t.Fatalf("NewGameID returned %q twice", a)
+	}
+	for _, id := range []string{a, b} {
+		if err := ValidateGameID(id); err != nil {
+			t.Errorf("NewGameID returned invalid ID: %v", err)
+		}
+	}
+}
+
+// The server and every client share one broker, so each game's bindings must
+// only ever match that game's keys.
+func TestGameIsolation(t *testing.T) {
+	const username = "alice"
+	gameA, gameB := NewGameID(), NewGameID()
+
+	topic := []struct {
+		name    string
+		binding func(gameID string) string
+		key     func(gameID string) string
+	}{
+		{
+			name:    "moves",
+			binding: func(gameID string) string { return VisibleMovesKey(gameID, username) },
+			key:     func(gameID string) string { return VisibleMovesKey(gameID, username) },
+		},
+		{
+			name:    "spectated moves",
+			binding: ArmyMovesBinding,
+			key:     func(gameID string) string { return ArmyMovesKey(gameID, username) },
+		},
+		{
+			name:    "game chat",
+			binding: ChatGameKey,
+			key:     ChatGameKey,
+		},
+		{
+			name:    "wars",
+			binding: WarRecognitionsBinding,
+			key:     func(gameID string) string { return WarRecognitionsKey(gameID, username) },
+		},
+		{
+			name:    "turns",
+			binding: TurnsKey,
+			key:     TurnsKey,
+		},
+		{
+			name:    "player turns",
+			binding: func(gameID string) string { return TurnsPlayerKey(gameID, username) },
+			key:     func(gameID string) string { return TurnsPlayerKey(gameID, username) },
+		},
+		{
+			name:    "game logs",
+			binding: GameLogGameBinding,
+			key:     func(gameID string) string { return GameLogKey(gameID, username) },
+		},
+		{
+			name:    "army reports",
+			binding: ArmyReportGameBinding,
+			key:     func(gameID string) string { return ArmyReportKey(gameID, username) },
+		},
+	}
+	for _, tt := range topic {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, own := range []string{gameA, gameB} {
+				other := gameB
+				if own == gameB {
+					other = gameA
+				}
+				if !topicMatches(tt.binding(own), tt.key(own)) {
+					t.Errorf("binding %q does not match its own game's key %q", tt.binding(own), tt.key(own))
+				}
+				if topicMatches(tt.binding(own), tt.key(other)) {
+					t.Errorf("binding %q matches the other game's key %q", tt.binding(own), tt.key(other))
+				}
+			}
+		})
+	}
+
+	// pauses go through the direct exchange, so a game's key must differ from
+	// every other game's and from the server-wide one
+	t.Run("pauses", func(t *testing.T) {
+		if PauseGameKey(gameA) == PauseGameKey(gameB) {
+			t.Errorf("pause key %q is shared between games", PauseGameKey(gameA))
+		}
+		if PauseGameKey(gameA) == PauseKey {
+			t.Errorf("a game's pause key is the server-wide %q", PauseKey)
+		}
+	})
+
+	t.Run("queues", func(t *testing.T) {
+		queues := []struct {
+			name  string
+			queue func(gameID string) string
+		}{
+			{"moves", func(gameID string) string { return ArmyMovesQueue(gameID, username) }},
+			{"wars", WarRecognitionsQueue},
+			{"pause", func(gameID string) string { return PauseQueue(gameID, username) }},
+			{"turns", func(gameID string) string { return TurnsQueue(gameID, username) }},
+		}
+		for _, q := range queues {
+			if q.queue(gameA) == q.queue(gameB) {
+				t.Errorf("%s queue %q is shared between games", q.name, q.queue(gameA))
+			}
+		}
+	})
+}
+
+// Players of one game share its exchange, so the bindings a client makes for
+// itself must never match keys meant for another player.
+func TestPlayerIsolation(t *testing.T) {
+	gameID := NewGameID()
+	own := func(username string) []string {
+		return []string{
+			VisibleMovesKey(gameID, username),
+			TurnsPlayerKey(gameID, username),
+			ChatDirectKey(username),
+			ChatAllianceKey(username),
+		}
+	}
+	// every client binds these as well
+	shared := []string{ChatGlobalKey(), ChatGameKey(gameID), TurnsKey(gameID)}
+
+	bindings := append(own("bob"), shared...)
+	for _, key := range own("bob") {
+		if !matchesAny(bindings, key) {
+			t.Errorf("bob's bindings do not match their own key %q", key)
+		}
+	}
+	for _, other := range []string{"alice", "jimbob", "bobby"} {
+		for _, key := range own(other) {
+			if matchesAny(bindings, key) {
+				t.Errorf("bob's bindings match %s's key %q", other, key)
+			}
+		}
+	}
+}
+
+func matchesAny(bindings []string, key string) bool {
+	for _, b := range bindings {
+		if topicMatches(b, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// A kick deletes the player's queues by name, so one player's list must never
+// contain a queue of a player whose name merely ends the same way.
+func TestPlayerQueues(t *testing.T) {
+	gameID := NewGameID()
+	bob := map[string]bool{}
+	for _, q := range PlayerQueues(gameID, "bob") {
+		bob[q] = true
+	}
+	for _, q := range PlayerQueues(gameID, "jimbob") {
+		if bob[q] {
+			t.Errorf("queue %q belongs to both bob and jimbob", q)
+		}
+	}
+	if !bob[ArmyMovesQueue(gameID, "bob")] {
+		t.Errorf("bob's queues are missing %q", ArmyMovesQueue(gameID, "bob"))
+	}
+	if len(PlayerQueues("", "bob")) >= len(bob) {
+		t.Errorf("a player outside a game has game queues")
+	}
+}
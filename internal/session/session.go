@@ -0,0 +1,225 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrInvalidToken  = errors.New("invalid session token")
+	ErrBanned        = errors.New("username is banned")
+)
+
+type Session struct {
+	Username       string
+	Token          string
+	ConnectionName string
+	// Spectator sessions may watch games but never play in one.
+	Spectator    bool
+	RegisteredAt time.Time
+	LastSeen     time.Time
+}
+
+// Registry is the server's record of which usernames are in use. A name is
+// reclaimed once its session misses heartbeats for longer than the TTL.
+type Registry struct {
+	sessions map[string]Session
+	banned   map[string]bool
+	banFile  string
+	ttl      time.Duration
+	mu       *sync.Mutex
+}
+
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{
+		sessions: map[string]Session{},
+		banned:   map[string]bool{},
+		ttl:      ttl,
+		mu:       &sync.Mutex{},
+	}
+}
+
+// LoadBans reads the ban list from path, if it exists, and persists every
+// later ban or unban there.
+func (r *Registry) LoadBans(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.banFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read bans: %v", err)
+	}
+	names := []string{}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("could not decode bans: %v", err)
+	}
+	for _, name := range names {
+		r.banned[name] = true
+	}
+	return nil
+}
+
+func (r *Registry) Register(username, connectionName string, spectator bool) (Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.banned[username] {
+		return Session{}, fmt.Errorf("%s: %w", username, ErrBanned)
+	}
+	now := time.Now()
+	if s, ok := r.sessions[username]; ok && now.Sub(s.LastSeen) <= r.ttl {
+		return Session{}, fmt.Errorf("%s: %w", username, ErrUsernameTaken)
+	}
+	token, err := newToken()
+	if err != nil {
+		return Session{}, fmt.Errorf("could not issue session token: %v", err)
+	}
+	s := Session{
+		Username:       username,
+		Token:          token,
+		ConnectionName: connectionName,
+		Spectator:      spectator,
+		RegisteredAt:   now,
+		LastSeen:       now,
+	}
+	r.sessions[username] = s
+	return s, nil
+}
+
+func (r *Registry) Heartbeat(username, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[username]
+	if !ok || s.Token != token {
+		return ErrInvalidToken
+	}
+	s.LastSeen = time.Now()
+	r.sessions[username] = s
+	return nil
+}
+
+func (r *Registry) Validate(username, token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[username]
+	return ok && s.Token == token
+}
+
+// IsSpectator reports whether username is held by a spectator.
+func (r *Registry) IsSpectator(username string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[username].Spectator
+}
+
+func (r *Registry) Unregister(username, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[username]
+	if !ok || s.Token != token {
+		return ErrInvalidToken
+	}
+	delete(r.sessions, username)
+	return nil
+}
+
+// Kick ends the player's session regardless of its token.
+func (r *Registry) Kick(username string) (Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[username]
+	delete(r.sessions, username)
+	return s, ok
+}
+
+func (r *Registry) Ban(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.banned[username] = true
+	return r.saveBans()
+}
+
+func (r *Registry) Unban(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.banned, username)
+	return r.saveBans()
+}
+
+func (r *Registry) Banned() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := []string{}
+	for name := range r.banned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// saveBans must be called with r.mu held.
+func (r *Registry) saveBans() error {
+	if r.banFile == "" {
+		return nil
+	}
+	names := []string{}
+	for name := range r.banned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.banFile, data, 0644); err != nil {
+		return fmt.Errorf("could not write bans: %v", err)
+	}
+	return nil
+}
+
+// Expire drops every session not seen within the TTL and returns the
+// reclaimed usernames.
+func (r *Registry) Expire(now time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expired := []string{}
+	for username, s := range r.sessions {
+		if now.Sub(s.LastSeen) > r.ttl {
+			delete(r.sessions, username)
+			expired = append(expired, username)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+func (r *Registry) Sessions() []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := []Session{}
+	for _, s := range r.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Username < sessions[j].Username
+	})
+	return sessions
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
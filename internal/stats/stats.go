@@ -0,0 +1,195 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// record is what is saved for each player. LastGame lets a player's games be
+// counted from their wars alone.
+type record struct {
+	gamelogic.PlayerStats
+	LastGame string
+}
+
+// savedRecord is a record as written to the stats file, with snake_case keys
+// like every file the game writes.
+type savedRecord struct {
+	Username    string `json:"username"`
+	Games       int    `json:"games"`
+	Wins        int    `json:"wins"`
+	Losses      int    `json:"losses"`
+	Draws       int    `json:"draws"`
+	UnitsKilled int    `json:"units_killed"`
+	UnitsLost   int    `json:"units_lost"`
+	LastGame    string `json:"last_game"`
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(savedRecord{
+		Username:    r.Username,
+		Games:       r.Games,
+		Wins:        r.Wins,
+		Losses:      r.Losses,
+		Draws:       r.Draws,
+		UnitsKilled: r.UnitsKilled,
+		UnitsLost:   r.UnitsLost,
+		LastGame:    r.LastGame,
+	})
+}
+
+func (r *record) UnmarshalJSON(data []byte) error {
+	var saved savedRecord
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	*r = record{
+		PlayerStats: gamelogic.PlayerStats{
+			Username:    saved.Username,
+			Games:       saved.Games,
+			Wins:        saved.Wins,
+			Losses:      saved.Losses,
+			Draws:       saved.Draws,
+			UnitsKilled: saved.UnitsKilled,
+			UnitsLost:   saved.UnitsLost,
+		},
+		LastGame: saved.LastGame,
+	}
+	return nil
+}
+
+// Stats keeps every player's war record across games, saved to a JSON file
+// after each war.
+type Stats struct {
+	path    string
+	players map[string]*record
+	mu      *sync.Mutex
+}
+
+// Load reads the records saved at path, if there are any.
+func Load(path string) (*Stats, error) {
+	s := &Stats{
+		path:    path,
+		players: map[string]*record{},
+		mu:      &sync.Mutex{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read stats: %v", err)
+	}
+	records := []*record{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("could not decode stats: %v", err)
+	}
+	for _, r := range records {
+		s.players[r.Username] = r
+	}
+	return s, nil
+}
+
+// RecordWar adds a war to both players' records and saves them. The records
+// only change once they are saved, so a war that fails to save and is
+// delivered again is counted once.
+func (s *Stats) RecordWar(r gamelogic.WarResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attacker := s.player(r.Attacker, r.GameID)
+	defender := s.player(r.Defender, r.GameID)
+	switch r.Winner {
+	case r.Attacker:
+		attacker.Wins++
+		defender.Losses++
+	case r.Defender:
+		defender.Wins++
+		attacker.Losses++
+	default:
+		attacker.Draws++
+		defender.Draws++
+	}
+	attacker.UnitsLost += r.AttackerLosses
+	attacker.UnitsKilled += r.DefenderLosses
+	defender.UnitsLost += r.DefenderLosses
+	defender.UnitsKilled += r.AttackerLosses
+
+	players := make(map[string]*record, len(s.players)+2)
+	for username, rec := range s.players {
+		players[username] = rec
+	}
+	players[attacker.Username] = &attacker
+	players[defender.Username] = &defender
+	if err := s.save(players); err != nil {
+		return err
+	}
+	s.players = players
+	return nil
+}
+
+// Leaderboard ranks players by wins, then fewest losses, then units killed.
+// A limit of zero returns everyone.
+func (s *Stats) Leaderboard(limit int) []gamelogic.PlayerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	players := []gamelogic.PlayerStats{}
+	for _, r := range s.players {
+		players = append(players, r.PlayerStats)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.Losses != b.Losses {
+			return a.Losses < b.Losses
+		}
+		if a.UnitsKilled != b.UnitsKilled {
+			return a.UnitsKilled > b.UnitsKilled
+		}
+		return a.Username < b.Username
+	})
+	if limit > 0 && len(players) > limit {
+		players = players[:limit]
+	}
+	return players
+}
+
+// player returns a copy of a player's record, counting gameID as one of
+// their games. It must be called with s.mu held.
+func (s *Stats) player(username, gameID string) record {
+	r := record{PlayerStats: gamelogic.PlayerStats{Username: username}}
+	if old, ok := s.players[username]; ok {
+		r = *old
+	}
+	if gameID != "" && r.LastGame != gameID {
+		r.Games++
+		r.LastGame = gameID
+	}
+	return r
+}
+
+// save writes players to the stats file. It must be called with s.mu held.
+func (s *Stats) save(players map[string]*record) error {
+	records := []*record{}
+	for _, r := range players {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Username < records[j].Username
+	})
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("could not write stats: %v", err)
+	}
+	return nil
+}
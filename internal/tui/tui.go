@@ -0,0 +1,289 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/client"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/lineedit"
+)
+
+const (
+	maxFeed   = 500
+	minWidth  = 60
+	minHeight = 16
+)
+
+// UI shows a game full screen: the map and our army on the left, a feed of
+// everything the client prints on the right, and the command line along the
+// bottom.
+type UI struct {
+	c      *client.Client
+	gs     *gamelogic.GameState
+	editor *lineedit.Editor
+	fd     int
+
+	mu      *sync.Mutex
+	running bool
+	feed    []string
+}
+
+// New prepares the UI for c. Create it before the client subscribes to
+// anything so every handled message redraws the screen.
+func New(c *client.Client) (*UI, error) {
+	editor := gamelogic.LineEditor()
+	if editor == nil {
+		return nil, errors.New("the TUI needs a terminal")
+	}
+	ui := &UI{
+		c:      c,
+		gs:     c.GameState(),
+		editor: editor,
+		fd:     int(os.Stdin.Fd()),
+		mu:     &sync.Mutex{},
+	}
+	c.Observer = func(string, any) {
+		ui.redraw()
+	}
+	return ui, nil
+}
+
+// Run takes over the screen and reads commands until the player quits. It
+// returns client.ErrKicked if the server removed the player instead.
+func (ui *UI) Run() error {
+	ui.editor.Redraw(func(out io.Writer) {
+		io.WriteString(out, "\x1b[?1049h")
+	})
+	ui.editor.SetPrinter(ui.print)
+	ui.mu.Lock()
+	ui.running = true
+	ui.mu.Unlock()
+	defer func() {
+		ui.mu.Lock()
+		ui.running = false
+		ui.mu.Unlock()
+		ui.editor.SetPrinter(nil)
+		ui.editor.Redraw(func(out io.Writer) {
+			io.WriteString(out, "\x1b[?1049l")
+		})
+	}()
+
+	// resources and the terminal size change without a message to redraw on
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ui.redraw()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ui.c.PrintHelp()
+	for {
+		ui.redraw()
+		words, err := ui.c.GetInput()
+		if err != nil {
+			return err
+		}
+		if words == nil && gamelogic.InputClosed() {
+			ui.c.Quit()
+			return nil
+		}
+		if len(words) > 0 {
+			ui.addFeed("> " + strings.Join(words, " "))
+		}
+		if ui.c.Execute(words) {
+			return nil
+		}
+	}
+}
+
+func (ui *UI) redraw() {
+	ui.mu.Lock()
+	running := ui.running
+	ui.mu.Unlock()
+	if !running {
+		return
+	}
+	ui.editor.Redraw(ui.draw)
+}
+
+func (ui *UI) print(out io.Writer, lines []byte) {
+	for _, line := range strings.Split(string(lines), "\n") {
+		ui.addFeed(line)
+	}
+	ui.draw(out)
+}
+
+// addFeed keeps a line for the event feed, dropping the banners' rules and
+// blank lines that only make sense in a scrolling terminal.
+func (ui *UI) addFeed(line string) {
+	line = strings.TrimRight(line, " \r")
+	if strings.Trim(line, "-") == "" {
+		return
+	}
+	if strings.HasPrefix(line, "====") {
+		line = "* " + strings.Trim(line, "= ")
+	}
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.feed = append(ui.feed, line)
+	if len(ui.feed) > maxFeed {
+		ui.feed = ui.feed[len(ui.feed)-maxFeed:]
+	}
+}
+
+func (ui *UI) draw(out io.Writer) {
+	width, height, err := lineedit.Size(ui.fd)
+	if err != nil || width < minWidth || height < minHeight {
+		fmt.Fprintf(out, "\x1b[H\x1b[2Jthe terminal must be at least %dx%d for the TUI\x1b[%d;1H", minWidth, minHeight, max(height, 1))
+		return
+	}
+	leftWidth := (width - 3) / 2
+	rightWidth := width - 3 - leftWidth
+	rows := height - 3
+
+	left := ui.worldPane()
+	left = append(left, "")
+	left = append(left, ui.armyPane(rows-len(left))...)
+	right := ui.feedPane(rightWidth, rows-1)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1b[1;1H\x1b[7m%s\x1b[0m", fit(ui.header(), width))
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "\x1b[%d;1H%s │ %s", i+2, fit(line(left, i), leftWidth), fit(line(right, i), rightWidth))
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H%s", height-1, strings.Repeat("─", width))
+	fmt.Fprintf(&b, "\x1b[%d;1H", height)
+	io.WriteString(out, b.String())
+}
+
+func (ui *UI) header() string {
+	parts := []string{
+		" Peril",
+		"player: " + ui.gs.GetUsername(),
+		"game: " + ui.c.GameID(),
+		fmt.Sprintf("resources: %d", ui.gs.GetResources()),
+	}
+	if ui.gs.InTurnMode() {
+		parts = append(parts, "turns")
+	}
+	if ui.gs.IsPaused() {
+		parts = append(parts, "PAUSED")
+	}
+	if ui.gs.IsOver() {
+		parts = append(parts, fmt.Sprintf("GAME OVER: %s won", ui.gs.GetWinner()))
+	}
+	return strings.Join(parts, "   ")
+}
+
+// worldPane counts the units in each location: ours, our allies' and the
+// enemy's as last seen.
+func (ui *UI) worldPane() []string {
+	mine := map[gamelogic.Location]int{}
+	for _, u := range ui.gs.GetPlayerSnap().Units {
+		mine[u.Location]++
+	}
+	allied := map[gamelogic.Location]int{}
+	for _, p := range ui.gs.AlliedForces() {
+		for _, u := range p.Units {
+			allied[u.Location]++
+		}
+	}
+	enemies := ui.gs.KnownEnemies()
+
+	lines := []string{
+		"WORLD",
+		fmt.Sprintf("%-12s %6s %6s %6s", "location", "mine", "allied", "enemy"),
+	}
+	for _, loc := range gamelogic.Locations() {
+		lines = append(lines, fmt.Sprintf("%-12s %6s %6s %6s", loc, count(mine[loc]), count(allied[loc]), count(enemies[loc])))
+	}
+	return lines
+}
+
+func (ui *UI) armyPane(rows int) []string {
+	units := []gamelogic.Unit{}
+	for _, u := range ui.gs.GetPlayerSnap().Units {
+		units = append(units, u)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	lines := []string{
+		fmt.Sprintf("ARMY (%d units)", len(units)),
+		fmt.Sprintf("%4s  %-10s %s", "id", "rank", "location"),
+	}
+	for i, u := range units {
+		if len(lines) == rows-1 && i < len(units)-1 {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(units)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%4d  %-10s %s", u.ID, u.Rank, u.Location))
+	}
+	return lines
+}
+
+// feedPane wraps the newest feed lines to fit the pane, oldest at the top.
+func (ui *UI) feedPane(width, rows int) []string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	lines := []string{}
+	for i := len(ui.feed) - 1; i >= 0 && len(lines) < rows; i-- {
+		wrapped := wrap(ui.feed[i], width)
+		lines = append(wrapped, lines...)
+	}
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	return append([]string{"EVENTS"}, lines...)
+}
+
+func count(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func line(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// fit pads or cuts s to exactly width characters.
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+func wrap(s string, width int) []string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return []string{""}
+	}
+	lines := []string{}
+	for len(r) > width {
+		lines = append(lines, string(r[:width]))
+		r = r[width:]
+	}
+	return append(lines, string(r))
+}
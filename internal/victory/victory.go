@@ -0,0 +1,182 @@
+package victory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+const (
+	ConditionHold         = "hold"
+	ConditionLastStanding = "last_standing"
+	ConditionScore        = "score"
+)
+
+// Conditions configures how a game can be won. A zero HoldLocations or
+// ScoreLimit disables that condition.
+type Conditions struct {
+	HoldLocations int
+	HoldFor       time.Duration
+	LastStanding  bool
+	ScoreLimit    int
+}
+
+func (c Conditions) Validate() error {
+	if c.HoldLocations < 0 || c.HoldLocations > len(gamelogic.Locations()) {
+		return fmt.Errorf("can not hold %d of %d locations", c.HoldLocations, len(gamelogic.Locations()))
+	}
+	if c.HoldLocations > 0 && c.HoldFor < 0 {
+		return fmt.Errorf("hold duration must not be negative")
+	}
+	if c.ScoreLimit < 0 {
+		return fmt.Errorf("score limit must not be negative")
+	}
+	if c.HoldLocations == 0 && !c.LastStanding && c.ScoreLimit == 0 {
+		return fmt.Errorf("at least one win condition must be enabled")
+	}
+	return nil
+}
+
+type Result struct {
+	Winner string
+	// Allies are the winner's allies, who share the victory.
+	Allies    []string
+	Condition string
+	Reason    string
+	Territory map[gamelogic.Location]string
+}
+
+// Judge watches a single game. Every Evaluate scores a point for each
+// location a player owns, so the score rate follows the caller's interval.
+type Judge struct {
+	cond      Conditions
+	roster    []string
+	holdSince map[string]time.Time
+	scores    map[string]int
+	fielded   map[string]bool
+}
+
+func NewJudge(cond Conditions, roster []string) *Judge {
+	return &Judge{
+		cond:      cond,
+		roster:    append([]string{}, roster...),
+		holdSince: map[string]time.Time{},
+		scores:    map[string]int{},
+		fielded:   map[string]bool{},
+	}
+}
+
+// Evaluate checks the armies of the game against the win conditions. allies
+// maps each allied player to their allies: an alliance is the last one
+// standing together, and a winner's allies share the win.
+func (j *Judge) Evaluate(now time.Time, players []gamelogic.Player, allies map[string][]string) (Result, bool) {
+	owners := gamelogic.Territory(players)
+	held := map[string]int{}
+	for _, owner := range owners {
+		held[owner]++
+		j.scores[owner]++
+	}
+	result := func(winner, condition, reason string) (Result, bool) {
+		return Result{
+			Winner:    winner,
+			Allies:    j.inRoster(allies[winner]),
+			Condition: condition,
+			Reason:    reason,
+			Territory: owners,
+		}, true
+	}
+
+	if j.cond.LastStanding {
+		units := map[string]int{}
+		for _, p := range players {
+			units[p.Username] = len(p.Units)
+			if len(p.Units) > 0 {
+				j.fielded[p.Username] = true
+			}
+		}
+		// players who never fielded an army have not been eliminated yet
+		standing := []string{}
+		for _, username := range j.roster {
+			if !j.fielded[username] || units[username] > 0 {
+				standing = append(standing, username)
+			}
+		}
+		if len(standing) == 1 && len(j.roster) > 1 {
+			return result(standing[0], ConditionLastStanding, fmt.Sprintf("%s is the last player standing.", standing[0]))
+		}
+		if len(standing) > 1 && allAllied(standing, allies) {
+			sort.Strings(standing)
+			return result(standing[0], ConditionLastStanding, fmt.Sprintf("%s are the last alliance standing.", strings.Join(standing, ", ")))
+		}
+	}
+
+	if j.cond.HoldLocations > 0 {
+		for _, username := range j.sortedRoster() {
+			if held[username] < j.cond.HoldLocations {
+				delete(j.holdSince, username)
+				continue
+			}
+			since, ok := j.holdSince[username]
+			if !ok {
+				j.holdSince[username] = now
+				since = now
+			}
+			if now.Sub(since) >= j.cond.HoldFor {
+				return result(username, ConditionHold, fmt.Sprintf("%s held %d location(s) for %s.", username, held[username], j.cond.HoldFor))
+			}
+		}
+	}
+
+	if j.cond.ScoreLimit > 0 {
+		best := ""
+		for _, username := range j.sortedRoster() {
+			if j.scores[username] >= j.cond.ScoreLimit && (best == "" || j.scores[username] > j.scores[best]) {
+				best = username
+			}
+		}
+		if best != "" {
+			return result(best, ConditionScore, fmt.Sprintf("%s reached %d point(s).", best, j.scores[best]))
+		}
+	}
+	return Result{}, false
+}
+
+func (j *Judge) Scores() map[string]int {
+	scores := map[string]int{}
+	for _, username := range j.roster {
+		scores[username] = j.scores[username]
+	}
+	return scores
+}
+
+// inRoster keeps the usernames that play in the judged game.
+func (j *Judge) inRoster(usernames []string) []string {
+	kept := []string{}
+	for _, username := range usernames {
+		if slices.Contains(j.roster, username) {
+			kept = append(kept, username)
+		}
+	}
+	return kept
+}
+
+func allAllied(usernames []string, allies map[string][]string) bool {
+	for _, a := range usernames {
+		for _, b := range usernames {
+			if a != b && !slices.Contains(allies[a], b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (j *Judge) sortedRoster() []string {
+	roster := append([]string{}, j.roster...)
+	sort.Strings(roster)
+	return roster
+}
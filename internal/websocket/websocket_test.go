@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// handshake is a client's upgrade request, with the key from RFC 6455.
+func handshake(target string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	return r
+}
+
+func TestUpgrade(t *testing.T) {
+	upgraded := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err == nil {
+			c.Close()
+		}
+		upgraded <- err
+	}))
+	defer srv.Close()
+
+	nc, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	req := handshake(srv.URL + "/events")
+	req.Header.Set("Origin", srv.URL)
+	if err := req.Write(nc); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(nc), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("Sec-WebSocket-Accept = %q, want %q", got, want)
+	}
+	if err := <-upgraded; err != nil {
+		t.Errorf("Upgrade: %v", err)
+	}
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *http.Request)
+		status int
+	}{
+		{"not an upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, http.StatusBadRequest},
+		{"old version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"no key", func(r *http.Request) { r.Header.Del("Sec-WebSocket-Key") }, http.StatusBadRequest},
+		{"other site", func(r *http.Request) { r.Header.Set("Origin", "https://evil.example") }, http.StatusForbidden},
+		{"bad origin", func(r *http.Request) { r.Header.Set("Origin", "://") }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := handshake("http://peril.example/events")
+		tt.change(r)
+		w := httptest.NewRecorder()
+		if _, err := Upgrade(w, r); err == nil {
+			t.Errorf("%s: upgraded", tt.name)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+// pipe is a Conn with the client's end of the connection.
+func pipe(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	c := &Conn{
+		nc: server,
+		rw: bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)),
+		mu: &sync.Mutex{},
+	}
+	return c, client
+}
+
+// clientFrame is a frame as a browser sends it, always masked.
+func clientFrame(fin bool, op byte, payload []byte) []byte {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// readServerFrame reads one frame from the server, which must not be masked.
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0x80 == 0 {
+		t.Error("server frame is not final")
+	}
+	if head[1]&0x80 != 0 {
+		t.Error("server frame is masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(r, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestReadMessageUnmasksAndJoinsFragments(t *testing.T) {
+	c, client := pipe(t)
+	long := bytes.Repeat([]byte("peril "), 30)
+	go func() {
+		client.Write(clientFrame(false, opText, []byte("hello ")))
+		client.Write(clientFrame(true, opContinuation, long))
+	}()
+	msg, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if want := "hello " + string(long); string(msg) != want {
+		t.Errorf("message = %q, want %q", msg, want)
+	}
+}
+
+func TestReadMessageAnswersPings(t *testing.T) {
+	c, client := pipe(t)
+	got := make(chan []byte, 1)
+	go func() {
+		msg, _ := c.ReadMessage()
+		got <- msg
+	}()
+	client.Write(clientFrame(true, opPing, []byte("still there?")))
+	op, payload := readServerFrame(t, client)
+	if op != opPong || string(payload) != "still there?" {
+		t.Errorf("answered op %d %q, want a pong with the ping's payload", op, payload)
+	}
+	client.Write(clientFrame(true, opText, []byte("yes")))
+	if msg := <-got; string(msg) != "yes" {
+		t.Errorf("message = %q, want yes", msg)
+	}
+}
+
+func TestReadMessageClose(t *testing.T) {
+	c, client := pipe(t)
+	go client.Write(clientFrame(true, opClose, []byte{0x03, 0xe8, 'b', 'y', 'e'}))
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ReadMessage()
+		done <- err
+	}()
+	op, payload := readServerFrame(t, client)
+	if op != opClose || !bytes.Equal(payload, []byte{0x03, 0xe8}) {
+		t.Errorf("answered op %d %v, want a close with the status code", op, payload)
+	}
+	if err := <-done; !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage after close = %v, want io.EOF", err)
+	}
+}
+
+func TestReadMessageRejects(t *testing.T) {
+	unmasked := []byte{0x80 | opText, 2, 'h', 'i'}
+	tooLarge := []byte{0x80 | opBinary, 0x80 | 127}
+	tooLarge = binary.BigEndian.AppendUint64(tooLarge, maxMessageSize+1)
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"unmasked", unmasked},
+		{"too large", tooLarge},
+		{"unknown opcode", clientFrame(true, 0x3, []byte("?"))},
+	}
+	for _, tt := range tests {
+		c, client := pipe(t)
+		go client.Write(tt.frame)
+		if _, err := c.ReadMessage(); err == nil {
+			t.Errorf("%s: ReadMessage succeeded", tt.name)
+		}
+	}
+}
+
+func TestWriteTextLengths(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		c, client := pipe(t)
+		payload := []byte(strings.Repeat("x", n))
+		go c.WriteText(payload)
+		op, got := readServerFrame(t, client)
+		if op != opText || !bytes.Equal(got, payload) {
+			t.Errorf("%d bytes: read op %d with %d bytes", n, op, len(got))
+		}
+	}
+}
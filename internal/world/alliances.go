@@ -0,0 +1,110 @@
+package world
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// pair is two players of a game, in name order so either can look it up.
+type pair struct {
+	gameID string
+	a, b   string
+}
+
+func newPair(gameID, a, b string) pair {
+	if b < a {
+		a, b = b, a
+	}
+	return pair{gameID: gameID, a: a, b: b}
+}
+
+// Diplomacy applies a proposal, acceptance or break sent between two players
+// of the same game. An acceptance only forms an alliance when the other
+// player proposed it first.
+func (w *World) Diplomacy(d routing.Diplomacy) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, username := range []string{d.From, d.To} {
+		if a, ok := w.armies[username]; !ok || a.GameID != d.GameID {
+			return fmt.Errorf("%s is not playing game %s", username, d.GameID)
+		}
+	}
+	if d.From == d.To {
+		return fmt.Errorf("%s can not ally with themselves", d.From)
+	}
+	p := newPair(d.GameID, d.From, d.To)
+	switch d.Action {
+	case routing.DiplomacyPropose:
+		if w.alliances[p] {
+			return fmt.Errorf("%s and %s are already allied", d.From, d.To)
+		}
+		w.proposals[p] = d.From
+	case routing.DiplomacyAccept:
+		if w.proposals[p] != d.To {
+			return fmt.Errorf("%s has not proposed an alliance to %s", d.To, d.From)
+		}
+		delete(w.proposals, p)
+		w.alliances[p] = true
+	case routing.DiplomacyBreak:
+		delete(w.proposals, p)
+		if !w.alliances[p] {
+			return fmt.Errorf("%s and %s are not allied", d.From, d.To)
+		}
+		delete(w.alliances, p)
+	default:
+		return fmt.Errorf("%s is not a diplomacy action", d.Action)
+	}
+	return nil
+}
+
+func (w *World) Allied(gameID, a, b string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.alliances[newPair(gameID, a, b)]
+}
+
+// Alliances maps every allied player in a game to their allies.
+func (w *World) Alliances(gameID string) map[string][]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	allies := map[string][]string{}
+	for p := range w.alliances {
+		if p.gameID != gameID {
+			continue
+		}
+		allies[p.a] = append(allies[p.a], p.b)
+		allies[p.b] = append(allies[p.b], p.a)
+	}
+	for _, usernames := range allies {
+		sort.Strings(usernames)
+	}
+	return allies
+}
+
+// AlliedForces returns the current armies of a player's allies.
+func (w *World) AlliedForces(gameID, username string) []gamelogic.Player {
+	forces := []gamelogic.Player{}
+	for _, ally := range w.Alliances(gameID)[username] {
+		if a, ok := w.Army(ally); ok && a.GameID == gameID {
+			forces = append(forces, a.Player)
+		}
+	}
+	return forces
+}
+
+// forget drops every proposal and alliance of a player who left.
+func (w *World) forget(username string) {
+	for p := range w.proposals {
+		if p.a == username || p.b == username {
+			delete(w.proposals, p)
+		}
+	}
+	for p := range w.alliances {
+		if p.a == username || p.b == username {
+			delete(w.alliances, p)
+		}
+	}
+}
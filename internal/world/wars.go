@@ -0,0 +1,59 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// war is one the server passed on and has not yet seen the result of.
+type war struct {
+	gameID   string
+	attacker string
+	defender string
+	location gamelogic.Location
+}
+
+// DeclareWar remembers a war the server is passing on, with the units each
+// side has where it is fought, so its result is decided by the server and
+// not by whoever reports it.
+func (w *World) DeclareWar(gameID string, rw gamelogic.RecognitionOfWar) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	k := war{gameID: gameID, attacker: rw.Attacker.Username, defender: rw.Defender.Username, location: rw.Location()}
+	w.wars[k] = rw.At(k.location)
+}
+
+// WarResult is the result of a war the server declared, worked out from the
+// units each side had when it was declared.
+func (w *World) WarResult(gameID, attacker, defender string, loc gamelogic.Location) (gamelogic.WarResult, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, username := range []string{attacker, defender} {
+		if a, ok := w.armies[username]; !ok || a.GameID != gameID {
+			return gamelogic.WarResult{}, fmt.Errorf("%s is not playing game %s", username, gameID)
+		}
+	}
+	rw, ok := w.wars[war{gameID: gameID, attacker: attacker, defender: defender, location: loc}]
+	if !ok {
+		return gamelogic.WarResult{}, fmt.Errorf("%s never attacked %s in %s", attacker, defender, loc)
+	}
+	return rw.Result(gameID, rw.Winner()), nil
+}
+
+// SettleWar forgets a war once its result is recorded, so it can't be
+// recorded twice.
+func (w *World) SettleWar(r gamelogic.WarResult) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.wars, war{gameID: r.GameID, attacker: r.Attacker, defender: r.Defender, location: r.Location})
+}
+
+// forgetWars drops the unsettled wars of a player who left.
+func (w *World) forgetWars(username string) {
+	for k := range w.wars {
+		if k.attacker == username || k.defender == username {
+			delete(w.wars, k)
+		}
+	}
+}
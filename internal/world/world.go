@@ -0,0 +1,226 @@
+package world
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+type Army struct {
+	GameID    string
+	Player    gamelogic.Player
+	Resources int
+	UpdatedAt time.Time
+	// credits are units bought but not yet seen in a snapshot.
+	credits map[gamelogic.UnitRank]int
+}
+
+// World is the server's last known picture of every player's army, built from
+// the player snapshots carried by their messages.
+type World struct {
+	armies map[string]Army
+	// proposals remembers who proposed each alliance not yet accepted.
+	proposals map[pair]string
+	alliances map[pair]bool
+	// wars holds the wars passed on whose results haven't arrived.
+	wars map[war]gamelogic.RecognitionOfWar
+	// paidUnits drops units a snapshot adds without having bought them.
+	paidUnits bool
+	mu        *sync.RWMutex
+}
+
+func New() *World {
+	return &World{
+		armies:    map[string]Army{},
+		proposals: map[pair]string{},
+		alliances: map[pair]bool{},
+		wars:      map[war]gamelogic.RecognitionOfWar{},
+		mu:        &sync.RWMutex{},
+	}
+}
+
+// RequirePaidUnits makes the world ignore any unit a snapshot adds that
+// wasn't bought with Buy, so a client can't spawn for free by claiming
+// units. Units already known in the same game are always kept.
+func (w *World) RequirePaidUnits() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paidUnits = true
+}
+
+func (w *World) Update(gameID string, player gamelogic.Player) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.update(gameID, player)
+}
+
+// Move puts units where an order sent them on top of the army the server
+// already knows, so orders resolved together never undo each other.
+func (w *World) Move(gameID, username string, units []gamelogic.Unit) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	player := gamelogic.Player{Username: username, Units: map[int]gamelogic.Unit{}}
+	if a, ok := w.armies[username]; ok && a.GameID == gameID {
+		for id, u := range a.Player.Units {
+			player.Units[id] = u
+		}
+	}
+	for _, u := range units {
+		player.Units[u.ID] = u
+	}
+	w.update(gameID, player)
+}
+
+// Add records player's army unless something has already been heard of
+// them, so an older snapshot never overwrites a newer move.
+func (w *World) Add(gameID string, player gamelogic.Player) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.armies[player.Username]; ok {
+		return false
+	}
+	w.update(gameID, player)
+	return true
+}
+
+// Fund sets a player's balance at the start of a game. The server is the
+// only one that changes balances after that.
+func (w *World) Fund(gameID, username string, resources int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, ok := w.armies[username]
+	if !ok || a.GameID != gameID {
+		a = w.update(gameID, gamelogic.Player{Username: username})
+	}
+	a.Resources = resources
+	w.armies[username] = a
+}
+
+// Earn adds income to a player's balance.
+func (w *World) Earn(username string, amount int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, ok := w.armies[username]
+	if !ok {
+		return
+	}
+	a.Resources += amount
+	w.armies[username] = a
+}
+
+// Buy charges a player for a unit of rank and returns what is left, or fails
+// without charging when they can't afford it. The unit may then appear in
+// one of their snapshots.
+func (w *World) Buy(gameID, username string, rank gamelogic.UnitRank, cost int) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	a, ok := w.armies[username]
+	if !ok || a.GameID != gameID {
+		return 0, fmt.Errorf("%s is not playing game %s", username, gameID)
+	}
+	if cost > a.Resources {
+		return a.Resources, fmt.Errorf("insufficient funds: a(n) %s costs %d but you have %d", rank, cost, a.Resources)
+	}
+	a.Resources -= cost
+	credits := map[gamelogic.UnitRank]int{}
+	for k, v := range a.credits {
+		credits[k] = v
+	}
+	credits[rank]++
+	a.credits = credits
+	w.armies[username] = a
+	return a.Resources, nil
+}
+
+// Standings maps every player in a game to their balance.
+func (w *World) Standings(gameID string) map[string]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	standings := map[string]int{}
+	for username, a := range w.armies {
+		if a.GameID == gameID {
+			standings[username] = a.Resources
+		}
+	}
+	return standings
+}
+
+func (w *World) update(gameID string, player gamelogic.Player) Army {
+	old, known := w.armies[player.Username]
+	known = known && old.GameID == gameID
+	credits := map[gamelogic.UnitRank]int{}
+	if known {
+		for k, v := range old.credits {
+			credits[k] = v
+		}
+	}
+	units := map[int]gamelogic.Unit{}
+	for id, u := range player.Units {
+		if !w.paidUnits {
+			units[id] = u
+			continue
+		}
+		if prev, ok := old.Player.Units[id]; known && ok {
+			// a unit can move, but never change rank
+			u.Rank = prev.Rank
+			units[id] = u
+			continue
+		}
+		if credits[u.Rank] == 0 {
+			log.Printf("ignored unpaid %s %d of %s", u.Rank, id, player.Username)
+			continue
+		}
+		credits[u.Rank]--
+		units[id] = u
+	}
+	a := Army{
+		GameID: gameID,
+		Player: gamelogic.Player{
+			Username: player.Username,
+			Units:    units,
+		},
+		UpdatedAt: time.Now(),
+		credits:   credits,
+	}
+	if known {
+		a.Resources = old.Resources
+	}
+	w.armies[player.Username] = a
+	return a
+}
+
+func (w *World) Army(username string) (Army, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	a, ok := w.armies[username]
+	return a, ok
+}
+
+func (w *World) Remove(username string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.armies, username)
+	w.forget(username)
+	w.forgetWars(username)
+}
+
+// Armies lists the armies in a game, or in every game when gameID is empty.
+func (w *World) Armies(gameID string) []Army {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	armies := []Army{}
+	for _, a := range w.armies {
+		if gameID != "" && a.GameID != gameID {
+			continue
+		}
+		armies = append(armies, a)
+	}
+	sort.Slice(armies, func(i, j int) bool {
+		return armies[i].Player.Username < armies[j].Player.Username
+	})
+	return armies
+}
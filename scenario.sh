@@ -0,0 +1,30 @@
+#!/bin/bash
+
+# Check if a scenario directory was provided
+if [ -z "$1" ]; then
+  echo "Usage: $0 <scenario-directory>"
+  exit 1
+fi
+
+# Array to store process IDs
+declare -a pids
+
+# Start one headless client per script in the scenario
+for script in "$1"/*.txt; do
+  go run ./cmd/client -script "$script" > "${script%.txt}.out" 2>&1 &
+  pids+=($!)
+done
+
+# Fail if any client failed
+status=0
+for pid in "${pids[@]}"; do
+  if ! wait "$pid"; then
+    status=1
+  fi
+done
+if [ $status -ne 0 ]; then
+  echo "Scenario $1 failed, see $1/*.out"
+else
+  echo "Scenario $1 passed"
+fi
+exit $status